@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// benchmarkRun runs a 512x512 board for 100 turns and waits for the run to finish.
+func benchmarkRun(b *testing.B, p gol.Params) {
+	for i := 0; i < b.N; i++ {
+		events := make(chan gol.Event, 1000)
+		go gol.Run(p, events, nil)
+		for range events {
+		}
+	}
+}
+
+// BenchmarkStatic measures the static split of one equal strip per worker.
+func BenchmarkStatic(b *testing.B) {
+	for _, threads := range []int{1, 2, 4, 8, 16} {
+		p := gol.Params{Turns: 100, Threads: threads, ImageWidth: 512, ImageHeight: 512}
+		b.Run(fmt.Sprintf("%d_workers", threads), func(b *testing.B) {
+			benchmarkRun(b, p)
+		})
+	}
+}
+
+// BenchmarkTiles measures the shared tile queue with the same worker counts as BenchmarkStatic.
+func BenchmarkTiles(b *testing.B) {
+	for _, threads := range []int{1, 2, 4, 8, 16} {
+		for _, tileHeight := range []int{4, 16} {
+			p := gol.Params{Turns: 100, Threads: threads, ImageWidth: 512, ImageHeight: 512, TileHeight: tileHeight}
+			b.Run(fmt.Sprintf("%d_workers-tile%d", threads, tileHeight), func(b *testing.B) {
+				benchmarkRun(b, p)
+			})
+		}
+	}
+}
+
+// BenchmarkFlips measures a 512x512 board for 100 turns with a consumer that keeps its own board from the
+// flipped cells, as the SDL window does, with a CellFlipped event per cell and with a CellsFlipped event
+// per turn.
+func BenchmarkFlips(b *testing.B) {
+	for _, batch := range []bool{false, true} {
+		p := gol.Params{Turns: 100, Threads: 8, ImageWidth: 512, ImageHeight: 512, BatchFlips: batch}
+		b.Run(fmt.Sprintf("batch=%v", batch), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				board := make([]bool, p.ImageWidth*p.ImageHeight)
+				events := make(chan gol.Event, 1000)
+				go gol.Run(p, events, nil)
+				for event := range events {
+					switch e := event.(type) {
+					case gol.CellFlipped:
+						board[e.Cell.Y*p.ImageWidth+e.Cell.X] = !board[e.Cell.Y*p.ImageWidth+e.Cell.X]
+					case gol.CellsFlipped:
+						for _, cell := range e.Cells {
+							board[cell.Y*p.ImageWidth+cell.X] = !board[cell.Y*p.ImageWidth+cell.X]
+						}
+					}
+				}
+			}
+		})
+	}
+}
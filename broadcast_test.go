@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+func flipCell(board map[util.Cell]bool, cell util.Cell) {
+	if board[cell] {
+		delete(board, cell)
+	} else {
+		board[cell] = true
+	}
+}
+
+// followBoard keeps a board from the events of a subscriber, as the SDL window does, sleeping for delay
+// after every TurnComplete to make it a slow consumer. It returns the cells alive at the end, the
+// number of TurnComplete events received and the last turn they reported.
+func followBoard(events <-chan gol.Event, delay time.Duration) ([]util.Cell, int, int) {
+	board := make(map[util.Cell]bool)
+	turns, last := 0, 0
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			flipCell(board, e.Cell)
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				flipCell(board, cell)
+			}
+		case gol.TurnComplete:
+			turns++
+			last = e.CompletedTurns
+			time.Sleep(delay)
+		}
+	}
+	var alive []util.Cell
+	for cell := range board {
+		alive = append(alive, cell)
+	}
+	return alive, turns, last
+}
+
+// TestBroadcaster runs a world with a fast subscriber, two slow ones that may fall behind and one that
+// unsubscribes part way through, and checks that every remaining subscriber ends with the right board,
+// both with a CellFlipped event per cell and with CellsFlipped batches. Whether the slow ones do fall
+// behind depends on the speed of the machine, so TestBroadcasterBehind makes sure of it.
+func TestBroadcaster(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 64, ImageHeight: 64, BatchFlips: batch}
+		t.Run(fmt.Sprintf("batch=%v", batch), func(t *testing.T) {
+			testBroadcaster(t, p)
+		})
+	}
+}
+
+func testBroadcaster(t *testing.T, p gol.Params) {
+	expected := readAliveCells("check/images/64x64x100.pgm", p.ImageWidth, p.ImageHeight)
+
+	b := gol.NewBroadcaster()
+	tests := []struct {
+		policy gol.Policy
+		buffer int
+		delay  time.Duration
+	}{
+		{gol.Block, 1000, 0},
+		{gol.DropFlips, 10, time.Millisecond},
+		{gol.Coalesce, 10, time.Millisecond},
+	}
+	type result struct {
+		alive       []util.Cell
+		turns, last int
+	}
+	results := make([]chan result, len(tests))
+	for i, test := range tests {
+		results[i] = make(chan result, 1)
+		go func(events <-chan gol.Event, delay time.Duration, results chan<- result) {
+			alive, turns, last := followBoard(events, delay)
+			results <- result{alive, turns, last}
+		}(b.Subscribe(test.buffer, test.policy), test.delay, results[i])
+	}
+
+	// A blocking subscriber that stops reading would hold the run back for good unless it unsubscribes.
+	abandoned := b.Subscribe(1, gol.Block)
+	go func() {
+		<-abandoned
+		time.Sleep(100 * time.Millisecond)
+		b.Unsubscribe(abandoned)
+	}()
+
+	go gol.Run(p, b.Events(), nil)
+	for i, test := range tests {
+		select {
+		case r := <-results[i]:
+			if r.last != p.Turns {
+				t.Errorf("%v: expected the last TurnComplete to be for turn %v, got %v", test.policy, p.Turns, r.last)
+			}
+			if test.policy == gol.Block && r.turns != p.Turns {
+				t.Errorf("%v: expected %v TurnComplete events, got %v", test.policy, p.Turns, r.turns)
+			}
+			assertEqualBoard(t, r.alive, expected, p)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%v: the subscriber's channel was not closed within 10 seconds", test.policy)
+		}
+	}
+	if _, ok := <-abandoned; ok {
+		t.Error("Expected an unsubscribed channel to be closed")
+	}
+}
+
+// TestBroadcasterBehind sends far more turns than a subscriber's buffer holds while it is not reading,
+// and checks that under DropFlips and Coalesce it misses some of them but still ends at the last turn
+// with the right board. Cell (turn%8, 0) flips every turn, so after 100 turns only x = 1 to 4 are alive.
+func TestBroadcasterBehind(t *testing.T) {
+	for _, policy := range []gol.Policy{gol.DropFlips, gol.Coalesce} {
+		t.Run(policy.String(), func(t *testing.T) {
+			b := gol.NewBroadcaster()
+			events := b.Subscribe(10, policy)
+			for turn := 1; turn <= 100; turn++ {
+				b.Events() <- gol.CellFlipped{CompletedTurns: turn, Cell: util.Cell{X: turn % 8, Y: 0}}
+				b.Events() <- gol.TurnComplete{CompletedTurns: turn}
+			}
+			close(b.Events())
+
+			alive, turns, last := followBoard(events, 0)
+			if turns >= 100 {
+				t.Errorf("Expected the subscriber to miss some turns, got all %v", turns)
+			}
+			if last != 100 {
+				t.Errorf("Expected the last TurnComplete to be for turn 100, got %v", last)
+			}
+			expected := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 0}, {X: 3, Y: 0}, {X: 4, Y: 0}}
+			assertEqualBoard(t, alive, expected, gol.Params{ImageWidth: 8, ImageHeight: 1})
+		})
+	}
+}
@@ -0,0 +1,22 @@
+// Command golserver runs Game of Life simulations as a local service with an HTTP/JSON API, so that
+// they can be driven from scripts and dashboards without SDL. Runs without an uploaded world read
+// images/ and every run saves its images to out/runs/{id}/, both relative to the working directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String(
+		"addr",
+		"localhost:8080",
+		"Specify the address to listen on.")
+	flag.Parse()
+
+	fmt.Println("Listening on", *addr)
+	log.Fatal(http.ListenAndServe(*addr, newServer().handler()))
+}
@@ -0,0 +1,104 @@
+package main
+
+// viewPage is the live view served at GET /runs/{id}/view. It follows GET /runs/{id}/stream, drawing
+// the board on a canvas one pixel per cell, and sends the same keys as the SDL window to
+// POST /runs/{id}/keys.
+const viewPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Game of Life</title>
+<style>
+  body { margin: 0; background: #111; color: #ddd; font: 14px monospace; }
+  #status { padding: 6px 10px; white-space: pre; }
+  #board { display: block; margin: 0 auto; image-rendering: pixelated; image-rendering: crisp-edges; }
+</style>
+</head>
+<body>
+<div id="status">Connecting...</div>
+<canvas id="board"></canvas>
+<script>
+"use strict";
+const base = location.pathname.replace(/\/view$/, "");
+const canvas = document.getElementById("board");
+const context = canvas.getContext("2d");
+const statusLine = document.getElementById("status");
+let image = null, width = 0, height = 0;
+let turn = 0, alive = 0, state = "", ended = false, dirty = false;
+
+function bytes(b64) {
+  const text = atob(b64), out = new Uint8Array(text.length);
+  for (let i = 0; i < text.length; i++) out[i] = text.charCodeAt(i);
+  return out;
+}
+
+function setCell(i, on) {
+  const v = on ? 255 : 0, p = i * 4;
+  image.data[p] = image.data[p + 1] = image.data[p + 2] = v;
+  image.data[p + 3] = 255;
+}
+
+function flip(i) {
+  setCell(i, image.data[i * 4] === 0);
+}
+
+function resize() {
+  const scale = Math.max(1, Math.floor(Math.min(innerWidth / width, (innerHeight - 40) / height)));
+  canvas.style.width = width * scale + "px";
+  canvas.style.height = height * scale + "px";
+}
+
+function key(e) {
+  const data = JSON.parse(e.data);
+  width = data.width; height = data.height;
+  turn = data.turn; alive = data.alive; state = data.state;
+  canvas.width = width; canvas.height = height;
+  image = context.createImageData(width, height);
+  const cells = bytes(data.cells);
+  for (let i = 0; i < width * height; i++) setCell(i, cells[i >> 3] & (0x80 >> (i & 7)));
+  resize();
+  dirty = true;
+}
+
+function turnFrame(e) {
+  const data = JSON.parse(e.data), flips = bytes(data.flips);
+  let index = 0, value = 0, scale = 1;
+  for (const b of flips) {
+    value += (b & 0x7f) * scale;
+    scale *= 128;
+    if (b & 0x80) continue;
+    index += value % 2 ? -(value + 1) / 2 : value / 2;
+    flip(index);
+    value = 0; scale = 1;
+  }
+  turn = data.turn; alive = data.alive;
+  dirty = true;
+}
+
+const source = new EventSource(base + "/stream");
+source.addEventListener("key", key);
+source.addEventListener("turn", turnFrame);
+source.addEventListener("alive", e => { alive = JSON.parse(e.data).count; dirty = true; });
+source.addEventListener("state", e => { state = JSON.parse(e.data).state; dirty = true; });
+source.addEventListener("end", () => { ended = true; dirty = true; source.close(); });
+
+function draw() {
+  if (dirty && image) {
+    context.putImageData(image, 0, 0);
+    statusLine.textContent = "Turn " + turn + "   Alive " + alive + "   " + state + (ended ? "   (finished)" : "") +
+      "\np pause   s save   q quit   k kill   n next   b back   + faster   - slower";
+    dirty = false;
+  }
+  requestAnimationFrame(draw);
+}
+requestAnimationFrame(draw);
+
+addEventListener("resize", () => { if (image) resize(); });
+addEventListener("keydown", e => {
+  if (ended || e.ctrlKey || e.metaKey || e.altKey || e.key.length !== 1 || !"psqknb+-".includes(e.key)) return;
+  fetch(base + "/keys", {method: "POST", headers: {"Content-Type": "application/json"}, body: JSON.stringify({key: e.key})});
+});
+</script>
+</body>
+</html>
+`
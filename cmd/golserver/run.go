@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// maxLogged is the number of recent events each run keeps for GET /runs/{id}/events.
+const maxLogged = 1000
+
+// eventJSON is the JSON form of an event. Only the fields that the type of event has are filled in.
+type eventJSON struct {
+	Seq            int    `json:"seq"`
+	Type           string `json:"type"`
+	CompletedTurns int    `json:"completedTurns"`
+	Message        string `json:"message,omitempty"`
+	CellsCount     *int   `json:"cellsCount,omitempty"`
+	Filename       string `json:"filename,omitempty"`
+	NewState       string `json:"newState,omitempty"`
+	TurnsPerSecond *int   `json:"turnsPerSecond,omitempty"`
+}
+
+// toJSON translates an event into its JSON form.
+func toJSON(seq int, event gol.Event) eventJSON {
+	e := eventJSON{
+		Seq:            seq,
+		Type:           strings.TrimPrefix(fmt.Sprintf("%T", event), "gol."),
+		CompletedTurns: event.GetCompletedTurns(),
+		Message:        event.String(),
+	}
+	switch event := event.(type) {
+	case gol.AliveCellsCount:
+		e.CellsCount = &event.CellsCount
+	case gol.FinalTurnComplete:
+		count := len(event.Alive)
+		e.CellsCount = &count
+	case gol.ImageOutputComplete:
+		e.Filename = event.Filename
+	case gol.StateChange:
+		e.NewState = event.NewState.String()
+	case gol.SpeedChange:
+		e.TurnsPerSecond = &event.TurnsPerSecond
+	}
+	return e
+}
+
+// statusJSON is the JSON form of the state of a run.
+type statusJSON struct {
+	ID       string     `json:"id"`
+	Params   gol.Params `json:"params"`
+	Turn     int        `json:"turn"`
+	Alive    int        `json:"alive"`
+	State    string     `json:"state"`
+	Finished bool       `json:"finished"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// run is a simulation started through the API. Its events are consumed by a single goroutine that
+// keeps its own copy of the board, as the SDL window does, so that status and snapshots never have
+// to wait for the simulation.
+type run struct {
+	id         string
+	params     gol.Params
+	keyPresses chan rune
+	cancel     context.CancelFunc
+
+	mu       sync.Mutex
+	board    [][]byte
+	turn     int
+	alive    int
+	state    gol.State
+	finished bool
+	err      error
+	seq      int
+	log      []eventJSON
+
+	// frames are the most recent messages for the live view, kept only while it is being watched.
+	frames   []frame
+	frameSeq int
+	watchers int
+	// notify is closed and replaced whenever frames are added or the run finishes.
+	notify chan struct{}
+}
+
+// startRun starts a simulation of world, or of the image in images/ if world is nil. The flipped cells
+// are always asked for in batches, as they are only ever applied a turn at a time.
+func startRun(id string, p gol.Params, world [][]byte) *run {
+	p.BatchFlips = true
+	// Runs of the same size would otherwise overwrite each other's images.
+	p.OutDir = filepath.Join("out", "runs", id)
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &run{
+		id:         id,
+		params:     p,
+		keyPresses: make(chan rune, 10),
+		cancel:     cancel,
+		board:      make([][]byte, p.ImageHeight),
+		state:      gol.Executing,
+		notify:     make(chan struct{}),
+	}
+	for y := range r.board {
+		r.board[y] = make([]byte, p.ImageWidth)
+	}
+
+	events := make(chan gol.Event, 1000)
+	go func() {
+		err := gol.RunWorld(ctx, p, world, events, r.keyPresses, nil)
+		r.mu.Lock()
+		r.err = err
+		r.mu.Unlock()
+	}()
+	go r.consume(events)
+	return r
+}
+
+// consume applies the events of the run to its board and log until the run ends. The cells flipped in
+// a turn are only applied once the next event arrives, which is never before the whole turn has been
+// sent, so that a snapshot never shows half a turn.
+func (r *run) consume(events <-chan gol.Event) {
+	var pending []util.Cell
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			pending = append(pending, e.Cell)
+			continue
+		case gol.CellsFlipped:
+			pending = append(pending, e.Cells...)
+			continue
+		}
+
+		r.mu.Lock()
+		for _, cell := range pending {
+			r.board[cell.Y][cell.X] = ^r.board[cell.Y][cell.X]
+			if r.board[cell.Y][cell.X] == 255 {
+				r.alive++
+			} else {
+				r.alive--
+			}
+		}
+
+		switch e := event.(type) {
+		case gol.TurnComplete:
+			r.turn = e.CompletedTurns
+		case gol.WorkerMetrics:
+		case gol.StateChange:
+			r.state = e.NewState
+			r.record(event)
+		default:
+			r.record(event)
+		}
+		if r.watchers > 0 {
+			r.addFrames(event, pending)
+		}
+		r.mu.Unlock()
+		pending = pending[:0]
+	}
+
+	r.mu.Lock()
+	r.finished = true
+	r.state = gol.Quitting
+	close(r.notify)
+	r.mu.Unlock()
+}
+
+// record adds an event to the log. r.mu must be held.
+func (r *run) record(event gol.Event) {
+	r.turn = event.GetCompletedTurns()
+	r.seq++
+	r.log = append(r.log, toJSON(r.seq, event))
+	if len(r.log) > maxLogged {
+		r.log = r.log[len(r.log)-maxLogged:]
+	}
+}
+
+// status returns the JSON form of the state of the run.
+func (r *run) status() statusJSON {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := statusJSON{
+		ID:       r.id,
+		Params:   r.params,
+		Turn:     r.turn,
+		Alive:    r.alive,
+		State:    r.state.String(),
+		Finished: r.finished,
+	}
+	if r.err != nil {
+		s.Error = r.err.Error()
+	}
+	return s
+}
+
+// events returns the logged events after seq.
+func (r *run) events(seq int) []eventJSON {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := []eventJSON{}
+	for _, e := range r.log {
+		if e.Seq > seq {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// snapshot returns a copy of the board and the turn it shows.
+func (r *run) snapshot() ([][]byte, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	board := make([][]byte, len(r.board))
+	for y := range board {
+		board[y] = append([]byte(nil), r.board[y]...)
+	}
+	return board, r.turn
+}
+
+// press sends a keypress to the run, reporting false if the run has finished or too many keypresses
+// are already waiting.
+func (r *run) press(key rune) bool {
+	r.mu.Lock()
+	finished := r.finished
+	r.mu.Unlock()
+	if finished {
+		return false
+	}
+	select {
+	case r.keyPresses <- key:
+		return true
+	default:
+		return false
+	}
+}
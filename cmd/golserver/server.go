@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// maxUpload is the largest request body accepted when creating a run.
+const maxUpload = 64 << 20
+
+// maxCells, maxThreads, maxBlocks and maxHistory bound what a single run may ask for, as a run that is too
+// large would take the whole server down with it. Every run keeps a few copies of its world, and a turn in
+// its history can hold 4 bytes for each cell of the world, so maxHistoryCells bounds the history by its
+// length times the cells of the world, which keeps it under 1 GiB.
+const (
+	maxCells        = 4096 * 4096
+	maxThreads      = 256
+	maxBlocks       = 256 * 256
+	maxHistory      = 10000
+	maxHistoryCells = 1 << 28
+)
+
+// maxRunning is the number of runs that may be going at once. Creating another is refused until one finishes.
+const maxRunning = 16
+
+// maxFinished is the number of finished runs kept for their status and snapshots. Once there are more,
+// the oldest of them are forgotten as new runs are created.
+const maxFinished = 100
+
+// server keeps track of the runs started through the API.
+type server struct {
+	mu     sync.Mutex
+	runs   map[string]*run
+	nextID int
+	// running holds the runs that had not finished when last looked at, oldest first, and finished the
+	// ids of the runs that have, in the order they were found to have finished.
+	running  []*run
+	finished []string
+}
+
+func newServer() *server {
+	return &server{runs: make(map[string]*run)}
+}
+
+// handler routes the API:
+//
+//	POST   /runs                create a run; returns its status
+//	GET    /runs                the status of every run
+//	GET    /runs/{id}           the status of a run
+//	DELETE /runs/{id}           cancel a run and forget it
+//	POST   /runs/{id}/keys      press a key: {"key": "p"}, or any of s, q, k, n, b, + and -
+//	GET    /runs/{id}/events    events other than flipped cells and TurnComplete, after ?since=seq
+//	GET    /runs/{id}/snapshot  the board as a PGM image, or as JSON with ?format=json
+//	GET    /runs/{id}/stream    the live view as server-sent events
+//	GET    /runs/{id}/view      a page showing the live view, which also forwards keypresses
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/runs", s.handleRuns)
+	mux.HandleFunc("/runs/", s.handleRun)
+	return mux
+}
+
+// writeJSON sends v as the JSON body of a response.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends an error as a JSON body of the form {"error": "..."}.
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func (s *server) handleRuns(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		statuses := make([]statusJSON, 0, len(s.runs))
+		for _, r := range s.runs {
+			statuses = append(statuses, r.status())
+		}
+		s.mu.Unlock()
+		sort.Slice(statuses, func(i, j int) bool {
+			a, _ := strconv.Atoi(statuses[i].ID)
+			b, _ := strconv.Atoi(statuses[j].ID)
+			return a < b
+		})
+		writeJSON(w, http.StatusOK, statuses)
+	case http.MethodPost:
+		req.Body = http.MaxBytesReader(w, req.Body, maxUpload)
+		p, world, err := parseCreate(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.mu.Lock()
+		s.sweep()
+		if len(s.running) >= maxRunning {
+			s.mu.Unlock()
+			writeError(w, http.StatusTooManyRequests, fmt.Errorf("%v runs are already going", maxRunning))
+			return
+		}
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		r := startRun(id, p, world)
+		s.runs[id] = r
+		s.running = append(s.running, r)
+		s.mu.Unlock()
+		w.Header().Set("Location", "/runs/"+id)
+		writeJSON(w, http.StatusCreated, r.status())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// sweep moves the runs that have finished since it was last called from running to finished, then
+// forgets the oldest finished runs until no more than maxFinished are left. s.mu must be held.
+func (s *server) sweep() {
+	running := s.running[:0]
+	for _, r := range s.running {
+		switch _, kept := s.runs[r.id]; {
+		case !r.status().Finished:
+			running = append(running, r)
+		case kept:
+			s.finished = append(s.finished, r.id)
+		}
+	}
+	for i := len(running); i < len(s.running); i++ {
+		s.running[i] = nil
+	}
+	s.running = running
+
+	for len(s.finished) > maxFinished {
+		delete(s.runs, s.finished[0])
+		s.finished = s.finished[1:]
+	}
+}
+
+// forget drops a run, which still counts as running until it has stopped. s.mu must be held.
+func (s *server) forget(id string) {
+	delete(s.runs, id)
+	for i := range s.finished {
+		if s.finished[i] == id {
+			s.finished = append(s.finished[:i], s.finished[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *server) handleRun(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/runs/"), "/")
+	s.mu.Lock()
+	r, ok := s.runs[parts[0]]
+	s.mu.Unlock()
+	if !ok || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no run at %v", req.URL.Path))
+		return
+	}
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, r.status())
+	case action == "" && req.Method == http.MethodDelete:
+		r.cancel()
+		s.mu.Lock()
+		s.forget(r.id)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case action == "keys" && req.Method == http.MethodPost:
+		var body struct {
+			Key string `json:"key"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(body.Key) != 1 || !strings.Contains("psqknb+-", body.Key) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown key %q", body.Key))
+			return
+		}
+		if !r.press(rune(body.Key[0])) {
+			writeError(w, http.StatusConflict, errors.New("the run has finished or is busy"))
+			return
+		}
+		writeJSON(w, http.StatusAccepted, r.status())
+	case action == "events" && req.Method == http.MethodGet:
+		since, _ := strconv.Atoi(req.URL.Query().Get("since"))
+		writeJSON(w, http.StatusOK, r.events(since))
+	case action == "snapshot" && req.Method == http.MethodGet:
+		board, turn := r.snapshot()
+		if req.URL.Query().Get("format") == "json" {
+			alive := []cellJSON{}
+			for y := range board {
+				for x := range board[y] {
+					if board[y][x] == 255 {
+						alive = append(alive, cellJSON{x, y})
+					}
+				}
+			}
+			writeJSON(w, http.StatusOK, snapshotJSON{turn, r.params.ImageWidth, r.params.ImageHeight, alive, r.params.Generated()})
+			return
+		}
+		w.Header().Set("Content-Type", "image/x-portable-graymap")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%vx%vx%v.pgm\"",
+			r.params.ImageWidth, r.params.ImageHeight, turn))
+		if generated := r.params.Generated(); generated != "" {
+			// Not every PGM reader skips comments, so how the world was made goes in a header instead.
+			w.Header().Set("X-Gol-Generated", generated)
+		}
+		_ = util.WritePGM(w, board)
+	case action == "stream" && req.Method == http.MethodGet:
+		r.stream(w, req)
+	case action == "view" && req.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, viewPage)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no %v %v", req.Method, req.URL.Path))
+	}
+}
+
+type cellJSON struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type snapshotJSON struct {
+	Turn   int        `json:"turn"`
+	Width  int        `json:"width"`
+	Height int        `json:"height"`
+	Alive  []cellJSON `json:"alive"`
+	// Generated describes how the initial world was made, if it was not uploaded or read from an image.
+	Generated string `json:"generated,omitempty"`
+}
+
+// parseCreate reads the parameters and the initial world of a new run. The body is either the JSON
+// gol.Params on their own, which start from their Generator or else the image in images/, or a
+// multipart form with the parameters in a "params" field and a PGM or RLE file in a "world" field.
+// A size left out of the parameters is taken from the upload, and an RLE pattern is placed in the
+// middle of the world.
+func parseCreate(req *http.Request) (gol.Params, [][]byte, error) {
+	var p gol.Params
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+			return p, nil, err
+		}
+		return p, nil, checkParams(p, nil)
+	}
+
+	if err := req.ParseMultipartForm(maxUpload); err != nil {
+		return p, nil, err
+	}
+	if params := req.FormValue("params"); params != "" {
+		if err := json.Unmarshal([]byte(params), &p); err != nil {
+			return p, nil, err
+		}
+	}
+	file, _, err := req.FormFile("world")
+	if err == http.ErrMissingFile {
+		return p, nil, checkParams(p, nil)
+	} else if err != nil {
+		return p, nil, err
+	}
+	defer file.Close()
+
+	world, err := parseWorld(file, &p)
+	if err != nil {
+		return p, nil, err
+	}
+	return p, world, checkParams(p, world)
+}
+
+// parseWorld reads an uploaded PGM image or RLE pattern, filling in the size of the world if it is missing.
+func parseWorld(file io.Reader, p *gol.Params) ([][]byte, error) {
+	in := bufio.NewReader(file)
+	magic, _ := in.Peek(2)
+	if string(magic) == "P5" {
+		world, err := util.ParsePGMLimit(in, maxCells)
+		if err != nil {
+			return nil, err
+		}
+		if p.ImageWidth == 0 && p.ImageHeight == 0 {
+			p.ImageWidth, p.ImageHeight = len(world[0]), len(world)
+		}
+		if len(world) != p.ImageHeight || len(world[0]) != p.ImageWidth {
+			return nil, fmt.Errorf("the image is %vx%v but the parameters are for %vx%v",
+				len(world[0]), len(world), p.ImageWidth, p.ImageHeight)
+		}
+		return world, nil
+	}
+
+	pattern, err := util.ParseRLE(in)
+	if err != nil {
+		return nil, err
+	}
+	if p.ImageWidth == 0 && p.ImageHeight == 0 {
+		p.ImageWidth, p.ImageHeight = pattern.Width, pattern.Height
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkLimits(*p); err != nil {
+		return nil, err
+	}
+	return pattern.Centred(p.ImageWidth, p.ImageHeight)
+}
+
+// checkParams rejects parameters that gol.Run cannot work with, including those of a run without a world
+// or generator whose image is missing.
+func checkParams(p gol.Params, world [][]byte) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := checkLimits(p); err != nil {
+		return err
+	}
+	if p.ImageDir != "" || p.OutDir != "" || p.PatternFile != "" {
+		return errors.New("the image and output directories and pattern file cannot be set through the API")
+	}
+	if world == nil && p.Generator == "" {
+		return p.CheckImage()
+	}
+	return nil
+}
+
+// checkLimits rejects runs larger than the server is willing to hold in memory.
+func checkLimits(p gol.Params) error {
+	switch {
+	case int64(p.ImageWidth)*int64(p.ImageHeight) > maxCells:
+		return fmt.Errorf("a %vx%v world has more than the %v cells a run may have", p.ImageWidth, p.ImageHeight, maxCells)
+	case p.Threads > maxThreads:
+		return fmt.Errorf("%v threads is more than the %v a run may have", p.Threads, maxThreads)
+	case int64(p.BlockRows)*int64(p.BlockCols) > maxBlocks:
+		return fmt.Errorf("a %vx%v grid of blocks has more than the %v blocks a run may have", p.BlockCols, p.BlockRows, maxBlocks)
+	case p.History > maxHistory:
+		return fmt.Errorf("a history of %v turns is more than the %v a run may keep", p.History, maxHistory)
+	case int64(p.History)*int64(p.ImageWidth)*int64(p.ImageHeight) > maxHistoryCells:
+		return fmt.Errorf("a history of %v turns of a %vx%v world is more than the %v cells a run may keep",
+			p.History, p.ImageWidth, p.ImageHeight, maxHistoryCells)
+	}
+	return nil
+}
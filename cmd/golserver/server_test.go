@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// upload builds a multipart request body creating a run from params and a world file.
+func upload(t *testing.T, params string, world []byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	util.Check(form.WriteField("params", params))
+	file, err := form.CreateFormFile("world", "world")
+	util.Check(err)
+	_, err = file.Write(world)
+	util.Check(err)
+	util.Check(form.Close())
+	return body, form.FormDataContentType()
+}
+
+// decode checks the status code of a response and decodes its JSON body into v.
+func decode(t *testing.T, res *http.Response, code int, v interface{}) {
+	defer res.Body.Close()
+	if res.StatusCode != code {
+		body, _ := ioutil.ReadAll(res.Body)
+		t.Fatalf("Expected status %v, got %v: %s", code, res.StatusCode, body)
+	}
+	if v != nil {
+		util.Check(json.NewDecoder(res.Body).Decode(v))
+	}
+}
+
+// awaitStatus polls a run until done reports true for its status, failing after 10 seconds.
+func awaitStatus(t *testing.T, url string, done func(statusJSON) bool) statusJSON {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		res, err := http.Get(url)
+		util.Check(err)
+		var status statusJSON
+		decode(t, res, http.StatusOK, &status)
+		if done(status) {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Gave up waiting for %v, last status %+v", url, status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestServer drives runs through the HTTP API, from an uploaded PGM image, an RLE pattern and a generator.
+func TestServer(t *testing.T) {
+	defer os.RemoveAll("out")
+	ts := httptest.NewServer(newServer().handler())
+	defer ts.Close()
+
+	t.Run("pgm", func(t *testing.T) {
+		image, err := ioutil.ReadFile("../../images/64x64.pgm")
+		util.Check(err)
+		body, contentType := upload(t, `{"Turns": 100, "Threads": 4}`, image)
+		var created statusJSON
+		res, err := http.Post(ts.URL+"/runs", contentType, body)
+		util.Check(err)
+		decode(t, res, http.StatusCreated, &created)
+		if created.Params.ImageWidth != 64 || created.Params.ImageHeight != 64 {
+			t.Fatalf("Expected the size to be taken from the image, got %vx%v", created.Params.ImageWidth, created.Params.ImageHeight)
+		}
+
+		url := ts.URL + "/runs/" + created.ID
+		status := awaitStatus(t, url, func(s statusJSON) bool { return s.Finished })
+		if status.Turn != 100 || status.Error != "" {
+			t.Fatalf("Expected to finish at turn 100 without an error, got %+v", status)
+		}
+
+		res, err = http.Get(url + "/snapshot")
+		util.Check(err)
+		defer res.Body.Close()
+		snapshot, err := util.ParsePGM(res.Body)
+		util.Check(err)
+		expected, err := os.Open("../../check/images/64x64x100.pgm")
+		util.Check(err)
+		defer expected.Close()
+		world, err := util.ParsePGM(expected)
+		util.Check(err)
+		for y := range world {
+			if !bytes.Equal(snapshot[y], world[y]) {
+				t.Fatalf("Snapshot differs from check/images/64x64x100.pgm in row %v", y)
+			}
+		}
+	})
+
+	t.Run("rle-keys", func(t *testing.T) {
+		glider := []byte("x = 3, y = 3, rule = B3/S23\nbob$2bo$3o!\n")
+		body, contentType := upload(t, `{"Turns": 100000000, "Threads": 2, "ImageWidth": 32, "ImageHeight": 32}`, glider)
+		var created statusJSON
+		res, err := http.Post(ts.URL+"/runs", contentType, body)
+		util.Check(err)
+		decode(t, res, http.StatusCreated, &created)
+		url := ts.URL + "/runs/" + created.ID
+
+		res, err = http.Post(url+"/keys", "application/json", strings.NewReader(`{"key": "x"}`))
+		util.Check(err)
+		decode(t, res, http.StatusBadRequest, nil)
+
+		res, err = http.Post(url+"/keys", "application/json", strings.NewReader(`{"key": "p"}`))
+		util.Check(err)
+		decode(t, res, http.StatusAccepted, nil)
+		paused := awaitStatus(t, url, func(s statusJSON) bool { return s.State == "Paused" })
+		if paused.Alive != 5 {
+			t.Fatalf("Expected a glider of 5 cells, got %v alive", paused.Alive)
+		}
+
+		var snapshot snapshotJSON
+		res, err = http.Get(url + "/snapshot?format=json")
+		util.Check(err)
+		decode(t, res, http.StatusOK, &snapshot)
+		if snapshot.Turn != paused.Turn || len(snapshot.Alive) != 5 {
+			t.Fatalf("Expected 5 cells at turn %v, got %v at turn %v", paused.Turn, len(snapshot.Alive), snapshot.Turn)
+		}
+
+		res, err = http.Post(url+"/keys", "application/json", strings.NewReader(`{"key": "k"}`))
+		util.Check(err)
+		decode(t, res, http.StatusAccepted, nil)
+		awaitStatus(t, url, func(s statusJSON) bool { return s.Finished })
+
+		var events []eventJSON
+		res, err = http.Get(url + "/events")
+		util.Check(err)
+		decode(t, res, http.StatusOK, &events)
+		var types []string
+		for _, e := range events {
+			if e.Type != "AliveCellsCount" {
+				types = append(types, e.Type)
+			}
+		}
+		if strings.Join(types, " ") != "StateChange ImageOutputComplete FinalTurnComplete StateChange" {
+			t.Fatalf("Unexpected events %v", types)
+		}
+
+		req, err := http.NewRequest(http.MethodDelete, url, nil)
+		util.Check(err)
+		res, err = http.DefaultClient.Do(req)
+		util.Check(err)
+		decode(t, res, http.StatusNoContent, nil)
+		res, err = http.Get(url)
+		util.Check(err)
+		decode(t, res, http.StatusNotFound, nil)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		glider := []byte("x = 3, y = 3, rule = B3/S23\nbob$2bo$3o!\n")
+		body, contentType := upload(t, `{"Turns": 100000000, "Threads": 2, "ImageWidth": 16, "ImageHeight": 16, "TurnsPerSecond": 200}`, glider)
+		var created statusJSON
+		res, err := http.Post(ts.URL+"/runs", contentType, body)
+		util.Check(err)
+		decode(t, res, http.StatusCreated, &created)
+		url := ts.URL + "/runs/" + created.ID
+
+		res, err = http.Get(url + "/view")
+		util.Check(err)
+		decode(t, res, http.StatusOK, nil)
+
+		res, err = http.Get(url + "/stream")
+		util.Check(err)
+		defer res.Body.Close()
+		if res.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("Expected an event stream, got %v", res.Header.Get("Content-Type"))
+		}
+
+		// Follow the stream on a board of our own, as the page does, until the run is killed.
+		var board []bool
+		var width, turns int
+		killed := false
+		in := bufio.NewScanner(res.Body)
+		in.Buffer(nil, 1<<20)
+		name := ""
+		for in.Scan() {
+			line := in.Text()
+			if strings.HasPrefix(line, "event: ") {
+				name = strings.TrimPrefix(line, "event: ")
+				continue
+			}
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			switch name {
+			case "key":
+				var key keyFrame
+				util.Check(json.Unmarshal(data, &key))
+				cells, err := base64.StdEncoding.DecodeString(key.Cells)
+				util.Check(err)
+				width = key.Width
+				board = make([]bool, key.Width*key.Height)
+				for i := range board {
+					board[i] = cells[i/8]&(0x80>>uint(i%8)) != 0
+				}
+			case "turn":
+				var turn turnFrame
+				util.Check(json.Unmarshal(data, &turn))
+				flips, err := base64.StdEncoding.DecodeString(turn.Flips)
+				util.Check(err)
+				index := 0
+				for len(flips) > 0 {
+					delta, n := binary.Varint(flips)
+					flips = flips[n:]
+					index += int(delta)
+					board[index] = !board[index]
+				}
+				alive := 0
+				for _, cell := range board {
+					if cell {
+						alive++
+					}
+				}
+				if alive != turn.Alive {
+					t.Fatalf("Expected %v cells alive at turn %v, counted %v", turn.Alive, turn.Turn, alive)
+				}
+				turns++
+				if turns == 20 && !killed {
+					res, err := http.Post(url+"/keys", "application/json", strings.NewReader(`{"key": "k"}`))
+					util.Check(err)
+					decode(t, res, http.StatusAccepted, nil)
+					killed = true
+				}
+			case "end":
+				if width != 16 || !killed {
+					t.Fatalf("Expected the stream to end after the run was killed, got width %v and %v turns", width, turns)
+				}
+				return
+			}
+		}
+		t.Fatalf("The stream ended without an end event: %v", in.Err())
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		params := `{"Turns": 10, "Threads": 2, "ImageWidth": 32, "ImageHeight": 32, "Generator": "c2", "Seed": 5}`
+		var created statusJSON
+		res, err := http.Post(ts.URL+"/runs", "application/json", strings.NewReader(params))
+		util.Check(err)
+		decode(t, res, http.StatusCreated, &created)
+		url := ts.URL + "/runs/" + created.ID
+		awaitStatus(t, url, func(s statusJSON) bool { return s.Finished })
+
+		var snapshot snapshotJSON
+		res, err = http.Get(url + "/snapshot?format=json")
+		util.Check(err)
+		decode(t, res, http.StatusOK, &snapshot)
+		if expected := "c2 seed=5 density=0.5"; snapshot.Generated != expected {
+			t.Errorf("Expected the snapshot to record %q, got %q", expected, snapshot.Generated)
+		}
+		res, err = http.Get(url + "/snapshot")
+		util.Check(err)
+		defer res.Body.Close()
+		if expected := "c2 seed=5 density=0.5"; res.Header.Get("X-Gol-Generated") != expected {
+			t.Errorf("Expected the PGM to be sent with %q, got %q", expected, res.Header.Get("X-Gol-Generated"))
+		}
+		header, _ := bufio.NewReader(res.Body).Peek(9)
+		if expected := "P5\n32 32\n"; string(header) != expected {
+			t.Errorf("Expected the PGM to start with %q, got %q", expected, header)
+		}
+
+		res, err = http.Post(ts.URL+"/runs", "application/json",
+			strings.NewReader(`{"Turns": 10, "Threads": 2, "ImageWidth": 32, "ImageHeight": 32, "Generator": "pattern", "PatternFile": "/etc/passwd"}`))
+		util.Check(err)
+		decode(t, res, http.StatusBadRequest, nil)
+	})
+
+	t.Run("missing-image", func(t *testing.T) {
+		res, err := http.Post(ts.URL+"/runs", "application/json",
+			strings.NewReader(`{"Turns": 10, "Threads": 2, "ImageWidth": 17, "ImageHeight": 17}`))
+		util.Check(err)
+		decode(t, res, http.StatusBadRequest, nil)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		for _, params := range []string{
+			`{"Turns": 10, "Threads": 2, "ImageWidth": 100000, "ImageHeight": 100000, "Generator": "random"}`,
+			`{"Turns": 10, "Threads": 100000, "ImageWidth": 32, "ImageHeight": 32, "Generator": "random"}`,
+			`{"Turns": 10, "Threads": 2, "ImageWidth": 32, "ImageHeight": 32, "History": 100000000, "Generator": "random"}`,
+			`{"Turns": 10, "Threads": 2, "ImageWidth": 4096, "ImageHeight": 4096, "History": 100, "Generator": "random"}`,
+			`{"Turns": 10, "Threads": 2, "ImageWidth": 4096, "ImageHeight": 4096, "BlockRows": 4096, "BlockCols": 4096, "Generator": "random"}`,
+		} {
+			res, err := http.Post(ts.URL+"/runs", "application/json", strings.NewReader(params))
+			util.Check(err)
+			decode(t, res, http.StatusBadRequest, nil)
+		}
+		glider := []byte("x = 3, y = 3, rule = B3/S23\nbob$2bo$3o!\n")
+		body, contentType := upload(t, `{"Turns": 10, "Threads": 2, "ImageWidth": 100000, "ImageHeight": 100000}`, glider)
+		res, err := http.Post(ts.URL+"/runs", contentType, body)
+		util.Check(err)
+		decode(t, res, http.StatusBadRequest, nil)
+
+		for _, header := range []string{"x = -1, y = -1", "x = 0, y = 3"} {
+			body, contentType = upload(t, `{"Turns": 10, "Threads": 2}`, []byte(header+"\no!\n"))
+			res, err = http.Post(ts.URL+"/runs", contentType, body)
+			util.Check(err)
+			decode(t, res, http.StatusBadRequest, nil)
+		}
+
+		// The header alone asks for far more memory than the server has, so it must be refused unread.
+		body, contentType = upload(t, `{"Turns": 10, "Threads": 2}`, []byte("P5 1 3000000000 255\n"))
+		res, err = http.Post(ts.URL+"/runs", contentType, body)
+		util.Check(err)
+		var failed map[string]string
+		decode(t, res, http.StatusBadRequest, &failed)
+		if !strings.Contains(failed["error"], "3000000000") {
+			t.Errorf("Expected the image to be refused for its size, got %q", failed["error"])
+		}
+	})
+}
+
+// TestEvictFinished checks that only the newest maxFinished finished runs are kept, and that runs still
+// going are never forgotten.
+func TestEvictFinished(t *testing.T) {
+	s := newServer()
+	for i := 0; i < maxFinished+10; i++ {
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		s.runs[id] = &run{id: id, finished: i != 0}
+		s.running = append(s.running, s.runs[id])
+	}
+	s.sweep()
+	if len(s.runs) != maxFinished+1 || len(s.running) != 1 || len(s.finished) != maxFinished {
+		t.Fatalf("Expected %v finished runs and one still going, got %v runs, %v running and %v finished",
+			maxFinished, len(s.runs), len(s.running), len(s.finished))
+	}
+	if _, ok := s.runs["1"]; !ok {
+		t.Error("Expected the run still going to be kept")
+	}
+	for id := 2; id <= 10; id++ {
+		if _, ok := s.runs[strconv.Itoa(id)]; ok {
+			t.Errorf("Expected finished run %v to be forgotten", id)
+		}
+	}
+
+	s.forget("11")
+	s.sweep()
+	if len(s.finished) != maxFinished-1 {
+		t.Errorf("Expected a deleted run to no longer count as finished, got %v finished", len(s.finished))
+	}
+}
+
+// TestMaxRunning checks that no more than maxRunning runs are started at once.
+func TestMaxRunning(t *testing.T) {
+	s := newServer()
+	for i := 0; i < maxRunning; i++ {
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		s.runs[id] = &run{id: id}
+		s.running = append(s.running, s.runs[id])
+	}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	params := `{"Turns": 10, "Threads": 2, "ImageWidth": 32, "ImageHeight": 32, "Generator": "random"}`
+	res, err := http.Post(ts.URL+"/runs", "application/json", strings.NewReader(params))
+	util.Check(err)
+	decode(t, res, http.StatusTooManyRequests, nil)
+
+	s.running[0].mu.Lock()
+	s.running[0].finished = true
+	s.running[0].mu.Unlock()
+	res, err = http.Post(ts.URL+"/runs", "application/json", strings.NewReader(params))
+	util.Check(err)
+	decode(t, res, http.StatusCreated, nil)
+}
+
+// TestFailedSave checks that a run whose image cannot be saved fails on its own, and that runs of the
+// same size save their images to directories of their own.
+func TestFailedSave(t *testing.T) {
+	defer os.RemoveAll("out")
+	util.Check(os.MkdirAll("out/runs", os.ModePerm))
+	util.Check(ioutil.WriteFile("out/runs/1", nil, 0644))
+	ts := httptest.NewServer(newServer().handler())
+	defer ts.Close()
+
+	params := `{"Turns": 10, "Threads": 2, "ImageWidth": 32, "ImageHeight": 32, "Generator": "random"}`
+	var ids []string
+	for i := 0; i < 3; i++ {
+		var created statusJSON
+		res, err := http.Post(ts.URL+"/runs", "application/json", strings.NewReader(params))
+		util.Check(err)
+		decode(t, res, http.StatusCreated, &created)
+		ids = append(ids, created.ID)
+	}
+
+	failed := awaitStatus(t, ts.URL+"/runs/"+ids[0], func(s statusJSON) bool { return s.Error != "" })
+	if failed.Turn != 10 || failed.State != "Quitting" {
+		t.Errorf("Expected the run to stop at turn 10, got %+v", failed)
+	}
+	for _, id := range ids[1:] {
+		status := awaitStatus(t, ts.URL+"/runs/"+id, func(s statusJSON) bool { return s.Finished })
+		if status.Error != "" {
+			t.Errorf("Expected run %v to finish without an error, got %v", id, status.Error)
+		}
+		if _, err := os.Stat("out/runs/" + id + "/32x32x10.pgm"); err != nil {
+			t.Errorf("Expected run %v to save its own image: %v", id, err)
+		}
+	}
+}
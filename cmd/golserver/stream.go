@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const (
+	// maxFrames is the number of messages kept for the live view. A viewer that falls further behind
+	// than this is sent the whole board again.
+	maxFrames = 256
+	// streamInterval is the shortest time between two writes to a viewer, so that fast runs are sent
+	// in batches of turns.
+	streamInterval = time.Second / 30
+)
+
+// frame is a message of the live view stream, already encoded as the data of a server-sent event.
+type frame struct {
+	seq  int
+	name string
+	data []byte
+}
+
+// turnFrame is sent for every turn. Flips holds the cells that flipped, as the difference between
+// each cell's index y*width+x and the previous one, zig-zag varint encoded and then base64 encoded.
+type turnFrame struct {
+	Turn  int    `json:"turn"`
+	Alive int    `json:"alive"`
+	Flips string `json:"flips"`
+}
+
+// keyFrame is sent when a viewer connects or falls behind. Cells holds one bit per cell in the order
+// of their indices, most significant bit first, base64 encoded.
+type keyFrame struct {
+	Turn   int    `json:"turn"`
+	Alive  int    `json:"alive"`
+	State  string `json:"state"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Cells  string `json:"cells"`
+}
+
+type aliveFrame struct {
+	Turn  int `json:"turn"`
+	Count int `json:"count"`
+}
+
+type stateFrame struct {
+	Turn  int    `json:"turn"`
+	State string `json:"state"`
+}
+
+// encodeFlips packs a list of cells as described by turnFrame.
+func encodeFlips(cells []util.Cell, width int) string {
+	buf := make([]byte, 0, 2*len(cells))
+	var tmp [binary.MaxVarintLen64]byte
+	previous := 0
+	for _, cell := range cells {
+		index := cell.Y*width + cell.X
+		n := binary.PutVarint(tmp[:], int64(index-previous))
+		buf = append(buf, tmp[:n]...)
+		previous = index
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// addFrame appends a message to the stream, forgetting the oldest once there are maxFrames of them.
+// r.mu must be held.
+func (r *run) addFrame(name string, v interface{}) {
+	data, _ := json.Marshal(v)
+	r.frameSeq++
+	r.frames = append(r.frames, frame{r.frameSeq, name, data})
+	if len(r.frames) > maxFrames {
+		r.frames = r.frames[len(r.frames)-maxFrames:]
+	}
+}
+
+// addFrames turns an event, and the cells flipped since the previous one, into messages for the live
+// view and wakes up the viewers. r.mu must be held.
+func (r *run) addFrames(event gol.Event, flipped []util.Cell) {
+	if _, ok := event.(gol.TurnComplete); ok || len(flipped) > 0 {
+		r.addFrame("turn", turnFrame{r.turn, r.alive, encodeFlips(flipped, r.params.ImageWidth)})
+	}
+	switch e := event.(type) {
+	case gol.AliveCellsCount:
+		r.addFrame("alive", aliveFrame{e.CompletedTurns, e.CellsCount})
+	case gol.StateChange:
+		r.addFrame("state", stateFrame{e.CompletedTurns, e.NewState.String()})
+	}
+	close(r.notify)
+	r.notify = make(chan struct{})
+}
+
+// keyFrame returns the whole board as a message. r.mu must be held.
+func (r *run) keyFrame() keyFrame {
+	width := r.params.ImageWidth
+	bits := make([]byte, (width*r.params.ImageHeight+7)/8)
+	for y := range r.board {
+		for x, cell := range r.board[y] {
+			if cell == 255 {
+				i := y*width + x
+				bits[i/8] |= 0x80 >> uint(i%8)
+			}
+		}
+	}
+	return keyFrame{
+		Turn:   r.turn,
+		Alive:  r.alive,
+		State:  r.state.String(),
+		Width:  width,
+		Height: r.params.ImageHeight,
+		Cells:  base64.StdEncoding.EncodeToString(bits),
+	}
+}
+
+// stream sends the live view of a run as server-sent events: a "key" event with the whole board, then
+// "turn", "alive" and "state" events as the run goes on, and finally "end" once the run has finished.
+func (r *run) stream(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	r.mu.Lock()
+	r.watchers++
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.watchers--
+		if r.watchers == 0 {
+			r.frames = nil
+		}
+		r.mu.Unlock()
+	}()
+
+	last := -1
+	for {
+		var frames []frame
+		r.mu.Lock()
+		if last < 0 || (len(r.frames) > 0 && r.frames[0].seq > last+1) {
+			data, _ := json.Marshal(r.keyFrame())
+			frames = append(frames, frame{name: "key", data: data})
+			last = r.frameSeq
+		}
+		for _, f := range r.frames {
+			if f.seq > last {
+				frames = append(frames, f)
+				last = f.seq
+			}
+		}
+		finished, notify := r.finished, r.notify
+		r.mu.Unlock()
+
+		for _, f := range frames {
+			fmt.Fprintf(w, "event: %v\ndata: %s\n\n", f.name, f.data)
+		}
+		if finished {
+			fmt.Fprint(w, "event: end\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-notify:
+		case <-req.Context().Done():
+			return
+		}
+		select {
+		case <-time.After(streamInterval):
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
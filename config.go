@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// A config file is a JSON object whose keys are the names of flags, along with threads, width and height
+// for -t, -w and -h, and whose values are what the flags would be given:
+//
+//	{
+//		"threads": 4, "turns": 1000, "noVis": true,
+//		"rule": "B3/S23", "boundary": "torus",
+//		"keys": {"pause": " "},
+//		"preset": "small",
+//		"presets": {
+//			"small": {"width": 16, "height": 16},
+//			"big": {"width": 512, "height": 512, "batch": true}
+//		}
+//	}
+//
+// A preset is an object of the same kind, applied on top of the rest of the file when it is named by
+// -preset, or by "preset" in the file if there is no -preset. Flags given on the command line always
+// win over the file.
+
+// settingAliases are the names config files may use for the flags with single letter names.
+var settingAliases = map[string]string{"threads": "t", "width": "w", "height": "h"}
+
+// keyActions are the actions that can be bound to keys in a config file, and the keys they are bound to
+// by default.
+var keyActions = map[string]rune{
+	"pause":  'p',
+	"save":   's',
+	"quit":   'q',
+	"kill":   'k',
+	"next":   'n',
+	"back":   'b',
+	"faster": '+',
+	"slower": '-',
+}
+
+// windowKeys are the keys of the controls the SDL window handles itself, which an action cannot be bound
+// to without shadowing them.
+var windowKeys = map[rune]string{
+	'f': "fit to window",
+	't': "theme",
+	'a': "age colouring",
+	'h': "overlay",
+	'g': "population graph",
+}
+
+// boundaries are the supported values of "boundary" in a config file. The world always wraps around.
+var boundaries = []string{"torus"}
+
+// config holds the settings of a config file that are not flags.
+type config struct {
+	// bindings maps each key bound in the file to the default key of its action.
+	bindings map[rune]rune
+}
+
+// loadConfig reads the config file at path and sets every flag it mentions that was not set on the command
+// line, first from the top level of the file and then from the preset. An empty preset uses the one named
+// in the file, if any.
+func loadConfig(path, preset string, fs *flag.FlagSet) (config, error) {
+	var c config
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	var file map[string]json.RawMessage
+	if err := json.Unmarshal(data, &file); err != nil {
+		return c, fmt.Errorf("%v: %v", path, err)
+	}
+
+	var presets map[string]map[string]json.RawMessage
+	if raw, ok := file["presets"]; ok {
+		if err := json.Unmarshal(raw, &presets); err != nil {
+			return c, fmt.Errorf("%v: presets: %v", path, err)
+		}
+		delete(file, "presets")
+	}
+	if raw, ok := file["preset"]; ok {
+		var name string
+		if err := json.Unmarshal(raw, &name); err != nil {
+			return c, fmt.Errorf("%v: preset: %v", path, err)
+		}
+		if preset == "" {
+			preset = name
+		}
+		delete(file, "preset")
+	}
+
+	fromCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		fromCommandLine[f.Name] = true
+	})
+	c.bindings = make(map[rune]rune)
+	if err := c.apply(file, fs, fromCommandLine); err != nil {
+		return c, fmt.Errorf("%v: %v", path, err)
+	}
+	if preset != "" {
+		settings, ok := presets[preset]
+		if !ok {
+			names := make([]string, 0, len(presets))
+			for name := range presets {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return c, fmt.Errorf("%v: no preset %q, expected one of %v", path, preset, names)
+		}
+		if err := c.apply(settings, fs, fromCommandLine); err != nil {
+			return c, fmt.Errorf("%v: preset %v: %v", path, preset, err)
+		}
+	}
+	if err := c.checkBindings(); err != nil {
+		return c, fmt.Errorf("%v: %v", path, err)
+	}
+	return c, nil
+}
+
+// apply sets the flags named in settings, other than those set on the command line, and reads the
+// settings that are not flags.
+func (c *config) apply(settings map[string]json.RawMessage, fs *flag.FlagSet, fromCommandLine map[string]bool) error {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		raw := settings[name]
+		switch name {
+		case "rule":
+			var rule string
+			if err := json.Unmarshal(raw, &rule); err != nil {
+				return fmt.Errorf("rule: %v", err)
+			}
+			if rule != gol.Rule {
+				return fmt.Errorf("rule %q is not supported, only %v is", rule, gol.Rule)
+			}
+		case "boundary":
+			var boundary string
+			if err := json.Unmarshal(raw, &boundary); err != nil {
+				return fmt.Errorf("boundary: %v", err)
+			}
+			if boundary != boundaries[0] {
+				return fmt.Errorf("boundary %q is not supported, expected one of %v", boundary, boundaries)
+			}
+		case "keys":
+			if err := c.bind(raw); err != nil {
+				return err
+			}
+		case "config", "preset", "presets":
+			return fmt.Errorf("%v cannot be set here", name)
+		default:
+			flagName := name
+			if alias, ok := settingAliases[name]; ok {
+				flagName = alias
+			}
+			if fs.Lookup(flagName) == nil {
+				return fmt.Errorf("unknown setting %q", name)
+			}
+			if fromCommandLine[flagName] {
+				continue
+			}
+			// Strings are given to the flag without their quotes, and numbers and booleans as they are.
+			value := string(raw)
+			var s string
+			if json.Unmarshal(raw, &s) == nil {
+				value = s
+			}
+			if err := fs.Set(flagName, value); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// bind reads the "keys" setting, an object from actions to single character keys.
+func (c *config) bind(raw json.RawMessage) error {
+	var keys map[string]string
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return fmt.Errorf("keys: %v", err)
+	}
+	for action, key := range keys {
+		defaultKey, ok := keyActions[action]
+		if !ok {
+			actions := make([]string, 0, len(keyActions))
+			for action := range keyActions {
+				actions = append(actions, action)
+			}
+			sort.Strings(actions)
+			return fmt.Errorf("keys: unknown action %q, expected one of %v", action, actions)
+		}
+		runes := []rune(key)
+		if len(runes) != 1 {
+			return fmt.Errorf("keys: %v must be a single character, not %q", action, key)
+		}
+		if control, ok := windowKeys[runes[0]]; ok {
+			return fmt.Errorf("keys: %q is the key of the window's %v control, so %v cannot be bound to it",
+				key, control, action)
+		}
+		if other, ok := c.bindings[runes[0]]; ok && other != defaultKey {
+			return fmt.Errorf("keys: %q is bound to more than one action", key)
+		}
+		c.bindings[runes[0]] = defaultKey
+	}
+	return nil
+}
+
+// checkBindings reports a key bound to an action that is the default key of another action, which would
+// be left without a key unless it has been bound to one of its own, as when two actions swap keys.
+func (c *config) checkBindings() error {
+	rebound := make(map[rune]bool)
+	for _, defaultKey := range c.bindings {
+		rebound[defaultKey] = true
+	}
+	actions := make([]string, 0, len(keyActions))
+	for action := range keyActions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		key := keyActions[action]
+		if bound, ok := c.bindings[key]; ok && bound != key && !rebound[key] {
+			return fmt.Errorf("keys: %q is the key of %v, which would be left without one", string(key), action)
+		}
+	}
+	return nil
+}
+
+// checkSettings reports the first setting that the run cannot work with, including a world whose size
+// does not match the image it would be read from.
+func checkSettings(p gol.Params, readsImage bool) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if !readsImage {
+		return nil
+	}
+	return p.CheckImage()
+}
+
+// exitIfInvalid prints err and exits with the status used for bad flags, if err is not nil.
+func exitIfInvalid(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid settings:", err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+const testConfig = `{
+	"threads": 2, "turns": 50, "rule": "B3/S23", "boundary": "torus",
+	"keys": {"pause": " "},
+	"preset": "small",
+	"presets": {
+		"small": {"width": 16, "height": 16},
+		"big": {"width": 512, "height": 512, "batch": true, "keys": {"quit": "x"}}
+	}
+}`
+
+// configFlags returns a flag set with a few of the flags of main, and the params they set.
+func configFlags() (*flag.FlagSet, *gol.Params) {
+	p := &gol.Params{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.IntVar(&p.Threads, "t", 8, "")
+	fs.IntVar(&p.ImageWidth, "w", 512, "")
+	fs.IntVar(&p.ImageHeight, "h", 512, "")
+	fs.IntVar(&p.Turns, "turns", 10000000000, "")
+	fs.BoolVar(&p.BatchFlips, "batch", false, "")
+	fs.StringVar(&p.ImageDir, "images", "images", "")
+	return fs, p
+}
+
+// TestConfig checks that config files and their presets set the flags they name, that the command line
+// wins over them, and that mistakes in them are reported.
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		file     string
+		preset   string
+		args     []string
+		expected gol.Params
+		bindings map[rune]rune
+		err      string
+	}{
+		{name: "default preset", file: testConfig,
+			expected: gol.Params{Threads: 2, ImageWidth: 16, ImageHeight: 16, Turns: 50, ImageDir: "images"},
+			bindings: map[rune]rune{' ': 'p'}},
+		{name: "named preset", file: testConfig, preset: "big",
+			expected: gol.Params{Threads: 2, ImageWidth: 512, ImageHeight: 512, Turns: 50, BatchFlips: true, ImageDir: "images"},
+			bindings: map[rune]rune{' ': 'p', 'x': 'q'}},
+		{name: "command line wins", file: testConfig, args: []string{"-t", "4", "-w", "64"},
+			expected: gol.Params{Threads: 4, ImageWidth: 64, ImageHeight: 16, Turns: 50, ImageDir: "images"},
+			bindings: map[rune]rune{' ': 'p'}},
+		{name: "no presets", file: `{"images": "check/images", "turns": 0}`,
+			expected: gol.Params{Threads: 8, ImageWidth: 512, ImageHeight: 512, ImageDir: "check/images"},
+			bindings: map[rune]rune{}},
+		{name: "unknown preset", file: testConfig, preset: "huge", err: `no preset "huge", expected one of [big small]`},
+		{name: "unknown setting", file: `{"colour": "red"}`, err: `unknown setting "colour"`},
+		{name: "wrong type", file: `{"threads": "many"}`, err: "threads: parse error"},
+		{name: "rule", file: `{"rule": "B36/S23"}`, err: `rule "B36/S23" is not supported`},
+		{name: "boundary", file: `{"boundary": "edge"}`, err: `boundary "edge" is not supported`},
+		{name: "unknown action", file: `{"keys": {"jump": "j"}}`, err: `unknown action "jump"`},
+		{name: "long key", file: `{"keys": {"pause": "space"}}`, err: "pause must be a single character"},
+		{name: "key bound twice", file: `{"keys": {"pause": "x", "quit": "x"}}`, err: "bound to more than one action"},
+		{name: "window key", file: `{"keys": {"pause": "g"}}`, err: `"g" is the key of the window's population graph control`},
+		{name: "default key of another action", file: `{"keys": {"pause": "q"}}`, err: `"q" is the key of quit`},
+		{name: "default key taken in a preset", file: `{"presets": {"a": {"keys": {"save": "k"}}}}`, preset: "a",
+			err: `"k" is the key of kill`},
+		{name: "swapped keys", file: `{"keys": {"pause": "q", "quit": "p"}}`,
+			expected: gol.Params{Threads: 8, ImageWidth: 512, ImageHeight: 512, Turns: 10000000000, ImageDir: "images"},
+			bindings: map[rune]rune{'q': 'p', 'p': 'q'}},
+		{name: "nested preset", file: `{"presets": {"a": {"preset": "b"}}}`, preset: "a", err: "preset a: preset cannot be set here"},
+		{name: "not json", file: `threads: 4`, err: "invalid character"},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, string('a'+rune(i))+".json")
+			if err := ioutil.WriteFile(path, []byte(test.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+			fs, p := configFlags()
+			if err := fs.Parse(test.args); err != nil {
+				t.Fatal(err)
+			}
+			c, err := loadConfig(path, test.preset, fs)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *p != test.expected {
+				t.Errorf("Expected %+v, got %+v", test.expected, *p)
+			}
+			if len(c.bindings) != len(test.bindings) {
+				t.Errorf("Expected bindings %v, got %v", test.bindings, c.bindings)
+			}
+			for key, action := range test.bindings {
+				if c.bindings[key] != action {
+					t.Errorf("Expected bindings %v, got %v", test.bindings, c.bindings)
+				}
+			}
+		})
+	}
+}
+
+// TestCheckSettings checks that impossible settings are rejected, including a size without an image.
+func TestCheckSettings(t *testing.T) {
+	tests := []struct {
+		name       string
+		p          gol.Params
+		readsImage bool
+		err        string
+	}{
+		{"valid", gol.Params{Threads: 4, ImageWidth: 16, ImageHeight: 16}, true, ""},
+		{"no threads", gol.Params{Threads: 0, ImageWidth: 16, ImageHeight: 16}, true, "at least one thread"},
+		{"negative size", gol.Params{Threads: 1, ImageWidth: -16, ImageHeight: 16}, true, "positive size"},
+		{"negative turns", gol.Params{Threads: 1, ImageWidth: 16, ImageHeight: 16, Turns: -1}, true, "turns cannot be negative"},
+		{"no image", gol.Params{Threads: 1, ImageWidth: 16, ImageHeight: 17}, true, "there is no 16x17 image in images"},
+		{"no image needed", gol.Params{Threads: 1, ImageWidth: 16, ImageHeight: 17}, false, ""},
+		{"other images", gol.Params{Threads: 1, ImageWidth: 64, ImageHeight: 64, ImageDir: "check"}, true, "there is no 64x64 image in check"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkSettings(test.p, test.readsImage)
+			if test.err == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// worldFromCells builds a world of the given size in which only the given cells are alive.
+func worldFromCells(cells []util.Cell, width, height int) [][]byte {
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+	}
+	for _, cell := range cells {
+		world[cell.Y][cell.X] = 255
+	}
+	return world
+}
+
+// TestEngine drives a 64x64 world through the Engine API and checks it against the expected images.
+func TestEngine(t *testing.T) {
+	p := gol.Params{Threads: 4, ImageWidth: 64, ImageHeight: 64}
+	initial := readAliveCells("images/64x64.pgm", p.ImageWidth, p.ImageHeight)
+	expected := readAliveCells("check/images/64x64x100.pgm", p.ImageWidth, p.ImageHeight)
+
+	engine, err := gol.New(p)
+	util.Check(err)
+	events := engine.Subscribe()
+	turns := make(chan int)
+	go func() {
+		completed := 0
+		for event := range events {
+			if _, ok := event.(gol.TurnComplete); ok {
+				completed++
+			}
+		}
+		turns <- completed
+	}()
+
+	if err := engine.Load(make([][]byte, 1)); err == nil {
+		t.Fatal("Expected an error loading a world of the wrong size")
+	}
+	invalid := worldFromCells(initial, p.ImageWidth, p.ImageHeight)
+	invalid[0][0] = 1
+	if err := engine.Load(invalid); err == nil {
+		t.Fatal("Expected an error loading a world with a cell that is neither 0 nor 255")
+	}
+	util.Check(engine.Load(worldFromCells(initial, p.ImageWidth, p.ImageHeight)))
+	assertEqualBoard(t, engine.Alive(), initial, p)
+
+	if turn := engine.Step(1); turn != 1 {
+		t.Fatalf("Expected Step(1) to reach turn 1, got %v", turn)
+	}
+
+	// A paused engine holds Step back until it is resumed.
+	engine.Pause()
+	stepped := make(chan int)
+	go func() {
+		stepped <- engine.Step(99)
+	}()
+	select {
+	case turn := <-stepped:
+		t.Fatalf("Step returned turn %v while paused", turn)
+	case <-time.After(200 * time.Millisecond):
+	}
+	engine.Resume()
+	if turn := <-stepped; turn != 100 {
+		t.Fatalf("Expected Step(99) to reach turn 100, got %v", turn)
+	}
+
+	assertEqualBoard(t, engine.Alive(), expected, p)
+	snapshot := engine.Snapshot()
+	var fromSnapshot []util.Cell
+	for y := range snapshot {
+		for x := range snapshot[y] {
+			if snapshot[y][x] == 255 {
+				fromSnapshot = append(fromSnapshot, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	assertEqualBoard(t, fromSnapshot, expected, p)
+
+	engine.Close()
+	if completed := <-turns; completed != 100 {
+		t.Fatalf("Expected 100 TurnComplete events, got %v", completed)
+	}
+	if turn := engine.Step(1); turn != 0 {
+		t.Fatalf("Expected Step on a closed engine to return 0, got %v", turn)
+	}
+	if err := engine.Load(snapshot); err == nil {
+		t.Fatal("Expected an error loading a world into a closed engine")
+	}
+	engine.Close()
+}
+
+// TestEngineStalled checks that invalid params are rejected, and that a subscriber that stops receiving
+// can unsubscribe, and does not stop the engine from being closed.
+func TestEngineStalled(t *testing.T) {
+	if _, err := gol.New(gol.Params{}); err == nil {
+		t.Error("Expected an error starting an engine without threads or a world")
+	}
+
+	p := gol.Params{Threads: 2, ImageWidth: 16, ImageHeight: 16}
+	world := worldFromCells([]util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}, 16, 16)
+
+	engine, err := gol.New(p)
+	util.Check(err)
+	stalled := engine.Subscribe()
+	go engine.Load(world)
+	<-stalled
+	unsubscribed := make(chan struct{})
+	go func() {
+		engine.Unsubscribe(stalled)
+		close(unsubscribed)
+	}()
+	select {
+	case <-unsubscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Unsubscribe did not return within 5 seconds while the subscriber had stopped receiving")
+	}
+	for range stalled {
+	}
+	if turn := engine.Step(10); turn != 10 {
+		t.Errorf("Expected Step(10) to reach turn 10 once the stalled subscriber had gone, got %v", turn)
+	}
+	engine.Close()
+
+	engine, err = gol.New(p)
+	util.Check(err)
+	util.Check(engine.Load(world))
+	stalled = engine.Subscribe()
+	go engine.Step(10)
+	<-stalled
+	closed := make(chan struct{})
+	go func() {
+		engine.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return within 5 seconds while a subscriber had stopped receiving")
+	}
+}
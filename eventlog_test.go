@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// recordRun runs p, recording its events to a log in memory, and returns the log along with every
+// event of the run that is recorded, or the first error recording them. The run is always drained to
+// the end, so that it is never left waiting for an event to be received.
+func recordRun(p gol.Params) (*bytes.Buffer, []gol.Event, error) {
+	log := &bytes.Buffer{}
+	ew, err := gol.NewEventWriter(log, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	events := make(chan gol.Event, 1000)
+	go gol.Run(p, events, nil)
+	var sent []gol.Event
+	for event := range events {
+		if err == nil {
+			err = ew.Write(event)
+		}
+		if _, ok := event.(gol.WorkerMetrics); !ok {
+			sent = append(sent, event)
+		}
+	}
+	if closeErr := ew.Close(); err == nil {
+		err = closeErr
+	}
+	return log, sent, err
+}
+
+// TestEventLog records runs and checks that replaying them sends the same events again, that a replay
+// can be paused and stopped like a live run, and that it cannot run backwards.
+func TestEventLog(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 64, ImageHeight: 64, BatchFlips: batch, Metrics: true}
+		t.Run(fmt.Sprintf("batch=%v", batch), func(t *testing.T) {
+			log, sent, err := recordRun(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			er, err := gol.NewEventReader(log)
+			util.Check(err)
+			if !reflect.DeepEqual(er.Params, p) {
+				t.Fatalf("Expected the log to start with %+v, got %+v", p, er.Params)
+			}
+			events := make(chan gol.Event, 1000)
+			replayErr := make(chan error, 1)
+			go func() {
+				replayErr <- gol.Replay(er, events, nil, 0)
+			}()
+			var replayed []gol.Event
+			for event := range events {
+				replayed = append(replayed, event)
+			}
+			if err := <-replayErr; err != nil {
+				t.Fatal(err)
+			}
+			if len(replayed) != len(sent) {
+				t.Fatalf("Expected %v events to be replayed, got %v", len(sent), len(replayed))
+			}
+			for i := range sent {
+				if !reflect.DeepEqual(sent[i], replayed[i]) {
+					t.Fatalf("Event %v was %#v, but was replayed as %#v", i, sent[i], replayed[i])
+				}
+			}
+		})
+	}
+
+	t.Run("keys", func(t *testing.T) {
+		log, _, err := recordRun(gol.Params{Turns: 100, Threads: 4, ImageWidth: 64, ImageHeight: 64})
+		if err != nil {
+			t.Fatal(err)
+		}
+		er, err := gol.NewEventReader(log)
+		util.Check(err)
+		events := make(chan gol.Event, 1000)
+		keyPresses := make(chan rune, 10)
+		// At a thousandth of the recorded speed the replay cannot finish before it is stopped.
+		replayErr := make(chan error, 1)
+		go func() {
+			replayErr <- gol.Replay(er, events, keyPresses, 0.001)
+		}()
+		keyPresses <- 'p'
+		keyPresses <- 'q'
+		var states []gol.State
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					if err := <-replayErr; err != nil {
+						t.Fatal(err)
+					}
+					if !reflect.DeepEqual(states, []gol.State{gol.Paused, gol.Quitting}) {
+						t.Fatalf("Expected the replay to pause and quit, got %v", states)
+					}
+					return
+				}
+				if e, ok := event.(gol.StateChange); ok {
+					states = append(states, e.NewState)
+				}
+			case <-deadline:
+				t.Fatal("The replay was not stopped within 5 seconds")
+			}
+		}
+	})
+
+	t.Run("negative speed", func(t *testing.T) {
+		log, _, err := recordRun(gol.Params{Turns: 1, Threads: 1, ImageWidth: 16, ImageHeight: 16})
+		if err != nil {
+			t.Fatal(err)
+		}
+		er, err := gol.NewEventReader(log)
+		util.Check(err)
+		events := make(chan gol.Event, 1000)
+		if err := gol.Replay(er, events, nil, -1); err == nil {
+			t.Error("Expected an error replaying at a negative speed")
+		}
+		if _, ok := <-events; ok {
+			t.Error("Expected events to be closed without any being sent")
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestGenerate checks that each generator makes the same world from the same seed, a different one from
+// another seed, and a world with the symmetry or repetition it promises.
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		p gol.Params
+		// same reports whether the cells at x, y and x2, y2 must match.
+		same func(x, y, x2, y2 int) bool
+	}{
+		{gol.Params{Generator: "random", ImageWidth: 48, ImageHeight: 32}, nil},
+		{gol.Params{Generator: "c2", ImageWidth: 48, ImageHeight: 32}, func(x, y, x2, y2 int) bool {
+			return x2 == 47-x && y2 == 31-y
+		}},
+		{gol.Params{Generator: "c4", ImageWidth: 33, ImageHeight: 33}, func(x, y, x2, y2 int) bool {
+			return x2 == 32-y && y2 == x
+		}},
+		{gol.Params{Generator: "d8", ImageWidth: 32, ImageHeight: 32}, func(x, y, x2, y2 int) bool {
+			return x2 == 31-y && y2 == x || x2 == y && y2 == x
+		}},
+		{gol.Params{Generator: "noise", ImageWidth: 48, ImageHeight: 32, SoupSize: 8}, func(x, y, x2, y2 int) bool {
+			return x%8 == x2%8 && y%8 == y2%8
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.p.Generator, func(t *testing.T) {
+			p := test.p
+			p.Seed = 42
+			world, err := gol.Generate(p)
+			util.Check(err)
+			again, err := gol.Generate(p)
+			util.Check(err)
+			if !reflect.DeepEqual(world, again) {
+				t.Error("Expected the same seed to make the same world")
+			}
+			p.Seed = 43
+			other, err := gol.Generate(p)
+			util.Check(err)
+			if reflect.DeepEqual(world, other) {
+				t.Error("Expected another seed to make another world")
+			}
+
+			alive := 0
+			for y := range world {
+				for x := range world[y] {
+					if world[y][x] == 255 {
+						alive++
+					}
+					if test.same == nil {
+						continue
+					}
+					for y2 := range world {
+						for x2 := range world[y2] {
+							if test.same(x, y, x2, y2) && world[y][x] != world[y2][x2] {
+								t.Fatalf("Expected cells %v,%v and %v,%v to match", x, y, x2, y2)
+							}
+						}
+					}
+				}
+			}
+			if cells := p.ImageWidth * p.ImageHeight; alive < cells/4 || alive > cells*3/4 {
+				t.Errorf("Expected about half of the %v cells to be alive, got %v", cells, alive)
+			}
+		})
+	}
+}
+
+// TestGenerateSoup checks that a soup smaller than the world is placed in the middle of it, that the
+// density is followed, and that a pattern is placed in the middle.
+func TestGenerateSoup(t *testing.T) {
+	p := gol.Params{Generator: "d8", ImageWidth: 32, ImageHeight: 24, SoupSize: 8, Density: 1}
+	world, err := gol.Generate(p)
+	util.Check(err)
+	for y := range world {
+		for x := range world[y] {
+			inside := x >= 12 && x < 20 && y >= 8 && y < 16
+			if (world[y][x] == 255) != inside {
+				t.Fatalf("Expected only the 8x8 soup in the middle to be alive, got %v at %v,%v", world[y][x], x, y)
+			}
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "generate")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "glider.rle")
+	util.Check(ioutil.WriteFile(path, []byte("x = 3, y = 3\nbo$2bo$3o!\n"), 0644))
+	world, err = gol.Generate(gol.Params{Generator: "pattern", PatternFile: path, ImageWidth: 9, ImageHeight: 9})
+	util.Check(err)
+	var alive []util.Cell
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == 255 {
+				alive = append(alive, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	expected := []util.Cell{{X: 4, Y: 3}, {X: 5, Y: 4}, {X: 3, Y: 5}, {X: 4, Y: 5}, {X: 5, Y: 5}}
+	if !reflect.DeepEqual(alive, expected) {
+		t.Errorf("Expected the glider in the middle at %v, got %v", expected, alive)
+	}
+}
+
+// TestGenerateInvalid checks that generator settings that cannot make a world are rejected.
+func TestGenerateInvalid(t *testing.T) {
+	tests := []struct {
+		p   gol.Params
+		err string
+	}{
+		{gol.Params{Generator: "c6"}, `unknown generator "c6"`},
+		{gol.Params{Generator: "random", Density: 1.5}, "density must be between 0 and 1"},
+		{gol.Params{Generator: "c2", SoupSize: -1}, "soup size cannot be negative"},
+		{gol.Params{Generator: "c4", SoupSize: 32}, "32x32 soup does not fit in a 16x16 world"},
+		{gol.Params{Generator: "pattern"}, "needs a PatternFile"},
+	}
+	for _, test := range tests {
+		t.Run(test.p.Generator, func(t *testing.T) {
+			p := test.p
+			p.Threads, p.ImageWidth, p.ImageHeight = 1, 16, 16
+			if err := p.Validate(); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+			if _, err := gol.Generate(p); err == nil {
+				t.Error("Expected Generate to fail too")
+			}
+		})
+	}
+}
+
+// TestRunGenerated checks that a run starts from the generated world, and that the images it saves are
+// plain PGMs with a JSON file next to them recording how that world was made.
+func TestRunGenerated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generated")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+	p := gol.Params{Turns: 20, Threads: 4, ImageWidth: 32, ImageHeight: 32, Generator: "c4", Seed: 7, OutDir: dir}
+
+	world, err := gol.Generate(p)
+	util.Check(err)
+	fromWorld := make(chan gol.Event)
+	plain := p
+	plain.Generator = ""
+	go gol.RunWorld(context.Background(), plain, world, fromWorld, nil, nil)
+	var expected []util.Cell
+	for event := range fromWorld {
+		if final, ok := event.(gol.FinalTurnComplete); ok {
+			expected = final.Alive
+		}
+	}
+
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for event := range events {
+		if final, ok := event.(gol.FinalTurnComplete); ok {
+			assertEqualBoard(t, final.Alive, expected, p)
+		}
+	}
+
+	name := filepath.Join(dir, fmt.Sprintf("32x32x%v", p.Turns))
+	assertEqualBoard(t, readAliveCells(name+".pgm", p.ImageWidth, p.ImageHeight), expected, p)
+
+	data, err := ioutil.ReadFile(name + ".json")
+	util.Check(err)
+	var origin map[string]interface{}
+	util.Check(json.Unmarshal(data, &origin))
+	expectedOrigin := map[string]interface{}{
+		"generated": "c4 seed=7 density=0.5", "generator": "c4", "seed": 7.0, "density": 0.5, "width": 32.0, "height": 32.0,
+	}
+	if !reflect.DeepEqual(origin, expectedOrigin) {
+		t.Errorf("Expected the image to be recorded as %v, got %v", expectedOrigin, origin)
+	}
+}
@@ -0,0 +1,37 @@
+package gol
+
+import "sync"
+
+// barrier is a reusable rendezvous point for a fixed number of goroutines.
+// Every call to wait blocks until all parties have called it, after which the barrier resets itself.
+type barrier struct {
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	parties    int
+	waiting    int
+	generation int
+}
+
+func newBarrier(parties int) *barrier {
+	b := &barrier{parties: parties}
+	b.cond = sync.NewCond(&b.mutex)
+	return b
+}
+
+// wait blocks until every party has reached the barrier.
+func (b *barrier) wait() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	generation := b.generation
+	b.waiting++
+	if b.waiting == b.parties {
+		b.waiting = 0
+		b.generation++
+		b.cond.Broadcast()
+		return
+	}
+	for generation == b.generation {
+		b.cond.Wait()
+	}
+}
@@ -0,0 +1,288 @@
+package gol
+
+import (
+	"sort"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Policy decides what a Broadcaster does with the events of a subscriber whose buffer is full.
+type Policy int
+
+const (
+	// Block waits for the subscriber to make room, holding back the run and every other subscriber.
+	Block Policy = iota
+	// DropFlips stops queueing CellFlipped, CellsFlipped, TurnComplete and WorkerMetrics events. Once
+	// there is room again the subscriber is resynchronised at the next turn with the cells whose state
+	// changed in the meantime, in a single CellsFlipped event if any were batched and as CellFlipped
+	// events otherwise, followed by a single TurnComplete. Every other event is still delivered.
+	DropFlips
+	// Coalesce resynchronises the subscriber as DropFlips does, but also keeps only the latest of the
+	// AliveCellsCount, SpeedChange and WorkerMetrics events missed in the meantime.
+	Coalesce
+)
+
+func (policy Policy) String() string {
+	switch policy {
+	case Block:
+		return "Block"
+	case DropFlips:
+		return "DropFlips"
+	case Coalesce:
+		return "Coalesce"
+	default:
+		return "Incorrect Policy"
+	}
+}
+
+// Broadcaster sends the events of one run to any number of subscribers, each with its own buffer and
+// policy for when it falls behind. Pass Events to Run, or to anything else that sends events, and
+// Subscribe as many consumers as needed before the first event is sent. Every subscriber's channel
+// is closed once Events has been closed and the subscriber has received everything queued for it.
+type Broadcaster struct {
+	events chan Event
+
+	mu          sync.Mutex
+	subscribers []*subscriber
+	closed      bool
+}
+
+// NewBroadcaster starts a broadcaster with no subscribers.
+func NewBroadcaster() *Broadcaster {
+	b := &Broadcaster{events: make(chan Event)}
+	go b.dispatch()
+	return b
+}
+
+// Events returns the channel the events of the run should be sent on. It must be closed after the
+// last event, as Run does.
+func (b *Broadcaster) Events() chan<- Event {
+	return b.events
+}
+
+// Subscribe returns a channel on which every later event is sent. Up to buffer events, and at least
+// one, are queued for it before policy applies.
+func (b *Broadcaster) Subscribe(buffer int, policy Policy) <-chan Event {
+	if buffer < 1 {
+		buffer = 1
+	}
+	s := &subscriber{
+		out:     make(chan Event),
+		stop:    make(chan struct{}),
+		buffer:  buffer,
+		policy:  policy,
+		flipped: make(map[util.Cell]bool),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.out)
+		return s.out
+	}
+	b.subscribers = append(b.subscribers, s)
+	go s.forward()
+	return s.out
+}
+
+// Unsubscribe stops sending events on a channel returned by Subscribe and closes it, dropping any
+// events still queued for it.
+func (b *Broadcaster) Unsubscribe(events <-chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, s := range b.subscribers {
+		if s.out == events {
+			b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+			s.mu.Lock()
+			s.stopped = true
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			close(s.stop)
+			return
+		}
+	}
+}
+
+// dispatch hands every event to each subscriber until Events is closed.
+func (b *Broadcaster) dispatch() {
+	for event := range b.events {
+		b.mu.Lock()
+		subscribers := append([]*subscriber(nil), b.subscribers...)
+		b.mu.Unlock()
+		for _, s := range subscribers {
+			s.put(event)
+		}
+	}
+
+	b.mu.Lock()
+	b.closed = true
+	for _, s := range b.subscribers {
+		s.mu.Lock()
+		if s.behind {
+			s.resync()
+		}
+		s.closed = true
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}
+	b.mu.Unlock()
+}
+
+// subscriber is the queue of events waiting for one subscriber. Once it has fallen behind, under any
+// policy but Block, the events it misses are folded into flipped and the fields after it until resync
+// queues them again.
+type subscriber struct {
+	out    chan Event
+	stop   chan struct{}
+	buffer int
+	policy Policy
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []Event
+	closed  bool
+	stopped bool
+
+	behind  bool
+	turn    int
+	flipped map[util.Cell]bool
+	batched bool
+	// The latest events of each kind missed while behind, or nil if there were none.
+	complete Event
+	metrics  Event
+	alive    Event
+	speed    Event
+}
+
+// put queues an event, applying the subscriber's policy if its buffer is full.
+func (s *subscriber) put(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.policy == Block {
+		for len(s.queue) >= s.buffer && !s.stopped {
+			s.cond.Wait()
+		}
+		s.push(event)
+		return
+	}
+
+	if !s.behind && len(s.queue) < s.buffer {
+		s.push(event)
+		return
+	}
+	s.behind = true
+	if !s.fold(event) {
+		s.resync()
+		s.push(event)
+		return
+	}
+	if _, ok := event.(TurnComplete); ok && len(s.queue) < s.buffer {
+		s.resync()
+	}
+}
+
+// fold records an event missed while behind, reporting false if the policy says it must be delivered.
+// s.mu must be held.
+func (s *subscriber) fold(event Event) bool {
+	switch e := event.(type) {
+	case CellFlipped:
+		s.flip(e.Cell)
+	case CellsFlipped:
+		for _, cell := range e.Cells {
+			s.flip(cell)
+		}
+		s.batched = true
+	case TurnComplete:
+		s.complete = e
+	case WorkerMetrics:
+		if s.policy == Coalesce {
+			s.metrics = e
+		}
+	case AliveCellsCount:
+		if s.policy != Coalesce {
+			return false
+		}
+		s.alive = e
+	case SpeedChange:
+		if s.policy != Coalesce {
+			return false
+		}
+		s.speed = e
+	default:
+		return false
+	}
+	s.turn = event.GetCompletedTurns()
+	return true
+}
+
+// flip records that a cell changed state while behind. s.mu must be held.
+func (s *subscriber) flip(cell util.Cell) {
+	if s.flipped[cell] {
+		delete(s.flipped, cell)
+	} else {
+		s.flipped[cell] = true
+	}
+}
+
+// resync queues the events folded while behind: the cells whose state changed, in row order, then the
+// latest of each other kind of event. s.mu must be held.
+func (s *subscriber) resync() {
+	cells := make([]util.Cell, 0, len(s.flipped))
+	for cell := range s.flipped {
+		cells = append(cells, cell)
+		delete(s.flipped, cell)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i].Y < cells[j].Y || cells[i].Y == cells[j].Y && cells[i].X < cells[j].X
+	})
+	if s.batched && len(cells) > 0 {
+		s.push(CellsFlipped{s.turn, cells})
+	} else {
+		for _, cell := range cells {
+			s.push(CellFlipped{s.turn, cell})
+		}
+	}
+	for _, event := range []Event{s.metrics, s.complete, s.alive, s.speed} {
+		if event != nil {
+			s.push(event)
+		}
+	}
+	s.complete, s.metrics, s.alive, s.speed = nil, nil, nil, nil
+	s.behind, s.batched = false, false
+}
+
+// push appends an event to the queue and wakes forward. s.mu must be held.
+func (s *subscriber) push(event Event) {
+	if s.stopped {
+		return
+	}
+	s.queue = append(s.queue, event)
+	s.cond.Broadcast()
+}
+
+// forward sends the queued events to the subscriber until the broadcaster is closed or it unsubscribes.
+func (s *subscriber) forward() {
+	defer close(s.out)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed && !s.stopped {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 || s.stopped {
+			s.mu.Unlock()
+			return
+		}
+		event := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.cond.Broadcast()
+		s.mu.Unlock()
+
+		select {
+		case s.out <- event:
+		case <-s.stop:
+			return
+		}
+	}
+}
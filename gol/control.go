@@ -0,0 +1,201 @@
+package gol
+
+import (
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// turnAction tells the distributor what to do once awaitTurn returns.
+type turnAction int
+
+const (
+	// proceed computes the next turn as planned.
+	proceed turnAction = iota
+	// reload throws away any generations already computed, because the world was changed in place by
+	// stepping back or editing cells.
+	reload
+	// stop ends the run.
+	stop
+)
+
+// maxTurnsPerSecond is the fastest throttled speed. Speeding up beyond it removes the throttle.
+const maxTurnsPerSecond = 1024
+
+// control holds the keyboard driven state of a run between turns.
+type control struct {
+	paused bool
+	// step lets exactly one turn through while paused.
+	step bool
+	// stopKey is the key that stopped the run, or 0 while it is still going.
+	stopKey rune
+	// err is why the run failed, such as the world not being saved when 's' was pressed.
+	err error
+	// turnsPerSecond is the target speed, or 0 when the run is not throttled.
+	turnsPerSecond int
+	// history holds the most recent turns so that 'b' can step back through them.
+	history *history
+
+	ticker  *time.Ticker
+	pacer   *time.Ticker
+	flatOut chan time.Time
+}
+
+func newControl(p Params) *control {
+	ctl := &control{
+		ticker:  time.NewTicker(2 * time.Second),
+		flatOut: make(chan time.Time),
+		history: newHistory(p.History),
+	}
+	close(ctl.flatOut)
+	ctl.setSpeed(p.TurnsPerSecond)
+	return ctl
+}
+
+// setSpeed changes the target speed, restarting the pacer to match. A speed beyond maxTurnsPerSecond is
+// slowed down to it, as the pacer cannot tick any faster than once a nanosecond.
+func (ctl *control) setSpeed(turnsPerSecond int) {
+	if ctl.pacer != nil {
+		ctl.pacer.Stop()
+		ctl.pacer = nil
+	}
+	if turnsPerSecond > maxTurnsPerSecond {
+		turnsPerSecond = maxTurnsPerSecond
+	}
+	ctl.turnsPerSecond = turnsPerSecond
+	if turnsPerSecond > 0 {
+		ctl.pacer = time.NewTicker(time.Second / time.Duration(turnsPerSecond))
+	}
+}
+
+func (ctl *control) stop() {
+	ctl.ticker.Stop()
+	ctl.setSpeed(0)
+}
+
+// setPaused pauses or resumes the run, telling the display if that changes anything.
+func (ctl *control) setPaused(c distributorChannels, paused bool, turn int) {
+	if paused == ctl.paused {
+		return
+	}
+	ctl.paused = paused
+	ctl.step = false
+	if paused {
+		c.events.emit(StateChange{turn, Paused})
+	} else {
+		c.events.emit(StateChange{turn, Executing})
+	}
+}
+
+// rewind undoes the most recent turn in the history by flipping its cells back in world, and returns
+// the turn the world is now at.
+func (ctl *control) rewind(p Params, c distributorChannels, world [][]byte, turn int) int {
+	flipped, ok := ctl.history.pop()
+	if !ok {
+		return turn
+	}
+	turn--
+	cells := newFlips(p, c, turn)
+	for _, i := range flipped {
+		x, y := int(i)%p.ImageWidth, int(i)/p.ImageWidth
+		world[y][x] = ^world[y][x]
+		cells.add(util.Cell{X: x, Y: y})
+	}
+	cells.send()
+	c.events.emit(TurnComplete{turn})
+	return turn
+}
+
+// handleKey acts on a keypress received between turns. 's' saves the current world, 'p' toggles the
+// pause, 'n' steps one turn and 'b' steps back one turn while paused, '+' and '-' double or halve the
+// speed, and 'q' or 'k' stop the run. It returns the turn the world is at afterwards.
+func (ctl *control) handleKey(p Params, c distributorChannels, key rune, world [][]byte, turn int) int {
+	switch key {
+	case 's':
+		ctl.err = writeWorld(p, c, world, turn)
+	case 'q', 'k':
+		ctl.stopKey = key
+	case 'p':
+		ctl.setPaused(c, !ctl.paused, turn)
+	case 'n':
+		if ctl.paused {
+			ctl.step = true
+		}
+	case 'b':
+		if ctl.paused {
+			turn = ctl.rewind(p, c, world, turn)
+		}
+	case '+':
+		switch {
+		case ctl.turnsPerSecond == 0:
+			return turn
+		case ctl.turnsPerSecond >= maxTurnsPerSecond:
+			ctl.setSpeed(0)
+		default:
+			ctl.setSpeed(ctl.turnsPerSecond * 2)
+		}
+		c.events.emit(SpeedChange{turn, ctl.turnsPerSecond})
+	case '-':
+		switch {
+		case ctl.turnsPerSecond == 0:
+			ctl.setSpeed(maxTurnsPerSecond)
+		case ctl.turnsPerSecond > 1:
+			ctl.setSpeed(ctl.turnsPerSecond / 2)
+		}
+		c.events.emit(SpeedChange{turn, ctl.turnsPerSecond})
+	}
+	return turn
+}
+
+// edit toggles a cell while paused and tells the display about it. The history no longer leads back
+// to the edited world, so it is forgotten.
+func (ctl *control) edit(p Params, c distributorChannels, cell util.Cell, world [][]byte, turn int) {
+	world[cell.Y][cell.X] = ^world[cell.Y][cell.X]
+	ctl.history.clear()
+	flipped := newFlips(p, c, turn)
+	flipped.add(cell)
+	flipped.send()
+	c.events.emit(TurnComplete{turn})
+}
+
+// awaitTurn services the ticker, keypresses, cell edits and engine requests until the next turn may be
+// processed, honouring pauses, single steps and the target speed. It returns straight away with reload
+// if the world was changed in place, or with stop, along with the turn the world is now at.
+func (ctl *control) awaitTurn(p Params, c distributorChannels, world [][]byte, turn int) (int, turnAction) {
+	for {
+		// A closed channel is always ready, so an unthrottled run goes straight through
+		// while still picking up any keypress or tick that is already waiting.
+		var pace <-chan time.Time = ctl.flatOut
+		if ctl.pacer != nil {
+			pace = ctl.pacer.C
+		}
+		if ctl.paused && !ctl.step {
+			pace = nil
+		}
+
+		select {
+		case <-ctl.ticker.C:
+			c.events.emit(AliveCellsCount{turn, len(calculateAliveCells(p, world))})
+		case key := <-c.keyPresses:
+			next := ctl.handleKey(p, c, key, world, turn)
+			if ctl.stopKey != 0 || ctl.err != nil {
+				return next, stop
+			}
+			if next != turn {
+				return next, reload
+			}
+		case cell := <-c.edits:
+			if ctl.paused && cell.X >= 0 && cell.Y >= 0 && cell.X < p.ImageWidth && cell.Y < p.ImageHeight {
+				ctl.edit(p, c, cell, world, turn)
+				return turn, reload
+			}
+		case r := <-c.requests:
+			if next, action := r(turn); action != proceed {
+				return next, action
+			}
+		case <-pace:
+			ctl.step = false
+			return turn, proceed
+		}
+	}
+}
@@ -1,33 +1,178 @@
 package gol
 
+import (
+	"fmt"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
 type distributorChannels struct {
-	events     chan<- Event
-	ioCommand  chan<- ioCommand
-	ioIdle     <-chan bool
-	ioFilename chan<- string
-	ioOutput   chan<- uint8
-	ioInput    <-chan uint8
+	events      *emitter
+	ioCommand   chan<- ioCommand
+	ioIdle      <-chan bool
+	ioFilename  chan<- string
+	ioOutput    chan<- uint8
+	ioOutputErr <-chan error
+	ioInput     <-chan uint8
+	ioInputErr  <-chan error
+	keyPresses  <-chan rune
+	edits       <-chan util.Cell
+	requests    <-chan request
+}
+
+// readWorld asks the io goroutine for the input image and builds the initial world from it.
+func readWorld(p Params, c distributorChannels) ([][]byte, error) {
+	c.ioCommand <- ioInput
+	c.ioFilename <- fmt.Sprintf("%dx%d", p.ImageWidth, p.ImageHeight)
+	if err := <-c.ioInputErr; err != nil {
+		return nil, err
+	}
+
+	world := makeWorld(p.ImageHeight, p.ImageWidth)
+	for y := 0; y < p.ImageHeight; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			world[y][x] = <-c.ioInput
+		}
+	}
+	return world, nil
+}
+
+// writeWorld sends the world to the io goroutine to be saved as a PGM image, and returns the error if
+// it could not be saved.
+func writeWorld(p Params, c distributorChannels, world [][]byte, turn int) error {
+	filename := fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, turn)
+	c.ioCommand <- ioOutput
+	c.ioFilename <- filename
+	for y := 0; y < p.ImageHeight; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			c.ioOutput <- world[y][x]
+		}
+	}
+	if err := <-c.ioOutputErr; err != nil {
+		return err
+	}
+	c.ioCommand <- ioCheckIdle
+	<-c.ioIdle
+	c.events.emit(ImageOutputComplete{turn, filename})
+	return nil
+}
+
+// flips sends the cells flipped by one turn, edit or load, either as they are added or, when
+// p.BatchFlips is set, as a single CellsFlipped event once they have all been added.
+type flips struct {
+	events *emitter
+	turn   int
+	batch  bool
+	cells  []util.Cell
+}
+
+func newFlips(p Params, c distributorChannels, turn int) *flips {
+	return &flips{events: c.events, turn: turn, batch: p.BatchFlips}
+}
+
+func (f *flips) add(cell util.Cell) {
+	if f.batch {
+		f.cells = append(f.cells, cell)
+	} else {
+		f.events.emit(CellFlipped{f.turn, cell})
+	}
 }
 
-// distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels) {
+// send emits the batched cells, if there are any.
+func (f *flips) send() {
+	if len(f.cells) > 0 {
+		f.events.emit(CellsFlipped{f.turn, f.cells})
+	}
+}
 
-	// TODO: Create a 2D slice to store the world.
+// calculateNextStates advances the pool by steps turns and returns the world after each of them.
+// The returned metrics are only filled in when p.Metrics is set.
+func calculateNextStates(p Params, pool *workerPool, turn, steps int) ([][][]byte, WorkerMetrics) {
+	var turnStart time.Time
+	if p.Metrics {
+		turnStart = time.Now()
+	}
 
-	turn := 0
+	generations, results := pool.step(steps)
 
+	metrics := WorkerMetrics{CompletedTurns: turn + steps, Turns: steps}
+	if p.Metrics {
+		metrics.Latency = time.Since(turnStart)
+		metrics.Workers = make([]WorkerTiming, len(results))
+		for i, result := range results {
+			compute := result.finished.Sub(result.started)
+			metrics.Workers[i] = WorkerTiming{
+				Worker:  i,
+				Rows:    result.rowCount,
+				Cells:   result.cellCount,
+				Compute: compute,
+				Wait:    metrics.Latency - compute,
+			}
+		}
+	}
+	return generations, metrics
+}
 
-	// TODO: Execute all turns of the Game of Life.
+// advance computes the next epoch of up to HaloDepth turns, without going past the target turn of the
+// Steps waiting on the engine. Before each turn is shown it waits for the go-ahead from the control,
+// which may also stop the epoch early or throw away the rest of it. The go-ahead for the first turn is
+// waited for before the epoch is computed, so that a paused run does no work that stepping back would
+// throw away.
+func (e *Engine) advance() {
+	p, c := e.p, e.c
+	// Nothing has been computed yet and the world is still the pool's, so toggling cells, placing a pattern
+	// or stepping back while paused changes it in place and costs no more than the cells it flips.
+	next, action := e.ctl.awaitTurn(p, c, e.world, e.turn)
+	if action != proceed {
+		e.turn = next
+		return
+	}
 
-	// TODO: Report the final state using FinalTurnCompleteEvent.
+	steps := p.HaloDepth
+	if steps < 1 {
+		steps = 1
+	}
+	if steps > e.target-e.turn {
+		steps = e.target - e.turn
+	}
 
+	generations, metrics := calculateNextStates(p, e.pool, e.turn, steps)
+	for i, newWorld := range generations {
+		if i > 0 {
+			if next, action := e.ctl.awaitTurn(p, c, e.world, e.turn); action != proceed {
+				e.discard(next)
+				return
+			}
+		}
 
-	// Make sure that the Io has finished any output before exiting.
-	c.ioCommand <- ioCheckIdle
-	<-c.ioIdle
+		e.flipped = e.flipped[:0]
+		flipped := newFlips(p, c, e.turn+1)
+		for y := 0; y < p.ImageHeight; y++ {
+			for x := 0; x < p.ImageWidth; x++ {
+				if newWorld[y][x] != e.world[y][x] {
+					flipped.add(util.Cell{X: x, Y: y})
+					if p.History > 0 {
+						e.flipped = append(e.flipped, int32(y*p.ImageWidth+x))
+					}
+				}
+			}
+		}
+		flipped.send()
+		e.ctl.history.push(e.flipped)
+		e.world = newWorld
+		e.turn++
+		if p.Metrics && e.turn == metrics.CompletedTurns {
+			c.events.emit(metrics)
+		}
+		c.events.emit(TurnComplete{e.turn})
+	}
+}
 
-	c.events <- StateChange{turn, Quitting}
-	
-	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
-	close(c.events)
+// discard throws away the rest of the epoch, as stepping back, editing or loading forks the timeline and
+// stopping may leave the world behind the pool, and carries on from the world at turn.
+func (e *Engine) discard(turn int) {
+	e.turn = turn
+	e.pool.load(e.world)
+	e.world = e.pool.world()
 }
@@ -0,0 +1,351 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// request is a piece of work done by the engine goroutine between turns. It is given the turn the world
+// is at and returns the turn afterwards, along with reload if it changed the world in place or stop if
+// the engine should stop stepping.
+type request func(turn int) (int, turnAction)
+
+// listener is a channel that an emitter sends events on.
+type listener struct {
+	events chan<- Event
+	// gone is closed once nothing more should be sent on events, even if it is not being received.
+	gone chan struct{}
+}
+
+// emitter sends every event to each listener in turn, waiting until each has received it.
+// Once done is closed events are dropped instead, so that a run can be torn down even if nobody is
+// receiving them any more. A listener whose gone channel is closed is dropped and its channel closed.
+type emitter struct {
+	listeners []*listener
+	done      <-chan struct{}
+}
+
+func (em *emitter) emit(event Event) {
+	kept := em.listeners[:0]
+	for _, l := range em.listeners {
+		select {
+		case l.events <- event:
+		case <-l.gone:
+			close(l.events)
+			continue
+		case <-em.done:
+		}
+		kept = append(kept, l)
+	}
+	em.listeners = kept
+}
+
+// remove drops a listener and closes its channel, unless it has already been dropped.
+func (em *emitter) remove(l *listener) {
+	for i := range em.listeners {
+		if em.listeners[i] == l {
+			em.listeners = append(em.listeners[:i], em.listeners[i+1:]...)
+			close(l.events)
+			return
+		}
+	}
+}
+
+// close closes every listener's channel.
+func (em *emitter) close() {
+	for _, l := range em.listeners {
+		close(l.events)
+	}
+	em.listeners = nil
+}
+
+// checkWorld reports an error if world is not ImageWidth x ImageHeight, or if any of its cells is neither
+// dead (0) nor alive (255). Edits and rewinds flip cells with ^, which only works on those two values.
+func checkWorld(p Params, world [][]byte) error {
+	if len(world) != p.ImageHeight {
+		return fmt.Errorf("gol: world has %v rows, expected %v", len(world), p.ImageHeight)
+	}
+	for y := range world {
+		if len(world[y]) != p.ImageWidth {
+			return fmt.Errorf("gol: row %v of the world has %v cells, expected %v", y, len(world[y]), p.ImageWidth)
+		}
+		for x, cell := range world[y] {
+			if cell != 0 && cell != 255 {
+				return fmt.Errorf("gol: cell (%v, %v) of the world is %v, expected 0 or 255", x, y, cell)
+			}
+		}
+	}
+	return nil
+}
+
+// Engine runs the Game of Life from Go code. Its world, workers and history are owned by a single
+// goroutine, so its methods may be called from any goroutine, including while another is in Step.
+// Every event of the run is sent to each channel returned by Subscribe, and the engine waits for
+// each of them to be received, so subscribers must keep draining their channels until they unsubscribe.
+type Engine struct {
+	p        Params
+	c        distributorChannels
+	requests chan request
+	done     chan struct{}
+	// closing is closed as soon as Close is called, so that events that are not received are dropped.
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	mu            sync.Mutex
+	subscriptions map[<-chan Event]*listener
+
+	// The fields below are only used by the engine goroutine.
+	pool    *workerPool
+	ctl     *control
+	world   [][]byte
+	turn    int
+	target  int
+	waiters []chan int
+	flipped []int32
+	closed  bool
+}
+
+// New starts an engine with an empty world at turn 0. It must be closed once it is no longer needed.
+// It returns the error from Validate if p is not valid, and uses no more threads than Clamp allows.
+func New(p Params) (*Engine, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	p, _ = p.Clamp()
+	return newEngine(p, distributorChannels{events: &emitter{}}), nil
+}
+
+// newEngine starts an engine whose turns can also be controlled through c's keyPresses and edits,
+// and which saves the world through c's io channels when 's' is pressed. Events stop being waited for
+// once the engine is closing.
+func newEngine(p Params, c distributorChannels) *Engine {
+	requests := make(chan request)
+	c.requests = requests
+	closing := make(chan struct{})
+	c.events.done = closing
+	e := &Engine{
+		p:             p,
+		c:             c,
+		requests:      requests,
+		done:          make(chan struct{}),
+		closing:       closing,
+		subscriptions: make(map[<-chan Event]*listener),
+		pool:          newWorkerPool(p, makeWorld(p.ImageHeight, p.ImageWidth)),
+		ctl:           newControl(p),
+	}
+	e.world = e.pool.world()
+	go e.loop()
+	return e
+}
+
+// loop is the engine goroutine. It steps the world while any Step is waiting and serves requests
+// the rest of the time.
+func (e *Engine) loop() {
+	for !e.closed {
+		switch {
+		case len(e.waiters) > 0 && (e.turn >= e.target || e.ctl.stopKey != 0 || e.ctl.err != nil):
+			for _, waiter := range e.waiters {
+				waiter <- e.turn
+			}
+			e.waiters = nil
+		case len(e.waiters) > 0:
+			e.advance()
+		default:
+			r := <-e.requests
+			e.turn, _ = r(e.turn)
+		}
+	}
+	for _, waiter := range e.waiters {
+		waiter <- e.turn
+	}
+	e.pool.stop()
+	e.ctl.stop()
+	e.c.events.close()
+	close(e.done)
+}
+
+// do runs r on the engine goroutine and waits for it to finish. It reports false if the engine has
+// already been closed, in which case r is not run.
+func (e *Engine) do(r request) bool {
+	finished := make(chan struct{})
+	wrapped := func(turn int) (int, turnAction) {
+		defer close(finished)
+		return r(turn)
+	}
+	select {
+	case e.requests <- wrapped:
+		<-finished
+		return true
+	case <-e.done:
+		return false
+	}
+}
+
+// Subscribe returns a channel on which every later event is sent. It is closed when the engine is closed
+// or the channel is passed to Unsubscribe.
+func (e *Engine) Subscribe() <-chan Event {
+	events := make(chan Event)
+	l := &listener{events: events, gone: make(chan struct{})}
+	e.mu.Lock()
+	e.subscriptions[events] = l
+	e.mu.Unlock()
+	if !e.do(func(turn int) (int, turnAction) {
+		e.c.events.listeners = append(e.c.events.listeners, l)
+		return turn, proceed
+	}) {
+		e.mu.Lock()
+		delete(e.subscriptions, events)
+		e.mu.Unlock()
+		close(events)
+	}
+	return events
+}
+
+// Unsubscribe stops sending events on a channel returned by Subscribe and closes it. It does not wait for
+// an event being sent on the channel to be received, so a subscriber that has stopped draining its
+// channel can still unsubscribe.
+func (e *Engine) Unsubscribe(events <-chan Event) {
+	e.mu.Lock()
+	l, ok := e.subscriptions[events]
+	delete(e.subscriptions, events)
+	e.mu.Unlock()
+	if !ok {
+		return
+	}
+	close(l.gone)
+	e.do(func(turn int) (int, turnAction) {
+		e.c.events.remove(l)
+		return turn, proceed
+	})
+}
+
+// Load replaces the world and starts counting turns from 0 again. A CellFlipped event is sent for every
+// cell that differs from the previous world, or a single CellsFlipped event if BatchFlips is set. A Step
+// in progress carries on from the new world for the rest of its turns.
+func (e *Engine) Load(world [][]byte) error {
+	if err := checkWorld(e.p, world); err != nil {
+		return err
+	}
+	if !e.do(func(turn int) (int, turnAction) {
+		flipped := newFlips(e.p, e.c, 0)
+		for y := range world {
+			for x := range world[y] {
+				if world[y][x] != e.world[y][x] {
+					e.world[y][x] = world[y][x]
+					flipped.add(util.Cell{X: x, Y: y})
+				}
+			}
+		}
+		flipped.send()
+		e.ctl.history.clear()
+		e.target -= turn
+		return 0, reload
+	}) {
+		return errors.New("gol: cannot load a world into a closed engine")
+	}
+	return nil
+}
+
+// Step advances the world by n turns and returns the turn it is at afterwards. It waits while the
+// engine is paused, and returns early if the engine is closed or the run is stopped with 'q' or 'k'.
+func (e *Engine) Step(n int) int {
+	finished := make(chan int, 1)
+	if !e.do(func(turn int) (int, turnAction) {
+		if len(e.waiters) == 0 {
+			e.target = turn
+			e.ctl.stopKey = 0
+		}
+		e.target += n
+		e.waiters = append(e.waiters, finished)
+		return turn, proceed
+	}) {
+		return 0
+	}
+	return <-finished
+}
+
+// Pause stops any Step between turns until Resume is called.
+func (e *Engine) Pause() {
+	e.do(func(turn int) (int, turnAction) {
+		e.ctl.setPaused(e.c, true, turn)
+		return turn, proceed
+	})
+}
+
+// Resume lets Step carry on after Pause.
+func (e *Engine) Resume() {
+	e.do(func(turn int) (int, turnAction) {
+		e.ctl.setPaused(e.c, false, turn)
+		return turn, proceed
+	})
+}
+
+// Snapshot returns a copy of the current world, indexed [y][x], in which 255 is alive and 0 is dead.
+func (e *Engine) Snapshot() [][]byte {
+	var world [][]byte
+	e.do(func(turn int) (int, turnAction) {
+		world = makeWorld(e.p.ImageHeight, e.p.ImageWidth)
+		for y := range world {
+			copy(world[y], e.world[y])
+		}
+		return turn, proceed
+	})
+	return world
+}
+
+// Alive returns the cells that are currently alive.
+func (e *Engine) Alive() []util.Cell {
+	var alive []util.Cell
+	e.do(func(turn int) (int, turnAction) {
+		alive = calculateAliveCells(e.p, e.world)
+		return turn, proceed
+	})
+	return alive
+}
+
+// Close stops the engine and its workers, ends any Step in progress and closes every subscriber's channel.
+// Events that are not received are dropped from then on, so it does not wait for subscribers that have
+// stopped draining their channels. It is safe to call more than once.
+func (e *Engine) Close() {
+	e.closeOnce.Do(func() {
+		close(e.closing)
+	})
+	e.do(func(turn int) (int, turnAction) {
+		e.closed = true
+		return turn, stop
+	})
+	<-e.done
+}
+
+// failure returns the error that ended the run early, if there was one.
+func (e *Engine) failure() error {
+	var err error
+	e.do(func(turn int) (int, turnAction) {
+		err = e.ctl.err
+		return turn, proceed
+	})
+	return err
+}
+
+// finish saves the final world and tells the subscribers that the run is over, as Run always has.
+// It returns the error if the world could not be saved, in which case the run is not reported as over.
+func (e *Engine) finish() error {
+	var err error
+	e.do(func(turn int) (int, turnAction) {
+		if err = writeWorld(e.p, e.c, e.world, turn); err != nil {
+			return turn, proceed
+		}
+		e.c.events.emit(FinalTurnComplete{turn, calculateAliveCells(e.p, e.world)})
+
+		// Make sure that the Io has finished any output before exiting.
+		e.c.ioCommand <- ioCheckIdle
+		<-e.c.ioIdle
+
+		e.c.events.emit(StateChange{turn, Quitting})
+		return turn, proceed
+	})
+	return err
+}
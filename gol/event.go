@@ -2,6 +2,8 @@ package gol
 
 import (
 	"fmt"
+	"time"
+
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -44,6 +46,14 @@ type StateChange struct { // implements Event
 	NewState       State
 }
 
+// SpeedChange is an Event notifying the user about a change of the target speed of execution.
+// This Event should be sent every time the speed is changed with '+' or '-'.
+// A TurnsPerSecond of 0 means the execution is not throttled.
+type SpeedChange struct { // implements Event
+	CompletedTurns int
+	TurnsPerSecond int
+}
+
 // CellFlipped is an Event notifying the GUI about a change of state of a single cell.
 // This even should be sent every time a cell changes state.
 // Make sure to send this event for all cells that are alive when the image is loaded in.
@@ -52,6 +62,14 @@ type CellFlipped struct { // implements Event
 	Cell           util.Cell
 }
 
+// CellsFlipped is an Event notifying the GUI about a change of state of many cells at once.
+// It is sent instead of CellFlipped when Params.BatchFlips is enabled, once for every turn, edit or load
+// that changes any cells, with the cells in row order. Like CellFlipped it is sent *before* TurnComplete.
+type CellsFlipped struct { // implements Event
+	CompletedTurns int
+	Cells          []util.Cell
+}
+
 // TurnComplete is an Event notifying the GUI about turn completion.
 // SDL will render a frame when this event is sent.
 // All CellFlipped events must be sent *before* TurnComplete.
@@ -67,6 +85,27 @@ type FinalTurnComplete struct {
 	Alive          []util.Cell
 }
 
+// WorkerTiming records how one worker spent a single turn. Rows counts the rows of every strip, tile or
+// block the worker computed, so with BlockCols columns of blocks the rows of all workers add up to
+// BlockCols times ImageHeight. Cells always add up to the size of the world.
+type WorkerTiming struct {
+	Worker  int
+	Rows    int
+	Cells   int
+	Compute time.Duration
+	Wait    time.Duration
+}
+
+// WorkerMetrics is an Event carrying per-worker timings for a completed turn.
+// This Event is only sent when Params.Metrics is enabled, just before the matching TurnComplete.
+// With a HaloDepth above one it covers every turn since the last synchronisation, and Turns says how many.
+type WorkerMetrics struct { // implements Event
+	CompletedTurns int
+	Turns          int
+	Latency        time.Duration
+	Workers        []WorkerTiming
+}
+
 // String methods allow the different types of Events and States to be printed.
 
 func (state State) String() string {
@@ -90,6 +129,17 @@ func (event StateChange) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event SpeedChange) String() string {
+	if event.TurnsPerSecond == 0 {
+		return fmt.Sprintf("Speed unlimited")
+	}
+	return fmt.Sprintf("Speed %v turns/s", event.TurnsPerSecond)
+}
+
+func (event SpeedChange) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event AliveCellsCount) String() string {
 	return fmt.Sprintf("Alive Cells %v", event.CellsCount)
 }
@@ -114,6 +164,14 @@ func (event CellFlipped) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CellsFlipped) String() string {
+	return fmt.Sprintf("")
+}
+
+func (event CellsFlipped) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event TurnComplete) String() string {
 	return fmt.Sprintf("")
 }
@@ -122,6 +180,14 @@ func (event TurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event WorkerMetrics) String() string {
+	return fmt.Sprintf("")
+}
+
+func (event WorkerMetrics) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event FinalTurnComplete) String() string {
 	return fmt.Sprintf("")
 }
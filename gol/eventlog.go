@@ -0,0 +1,397 @@
+package gol
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// logLine is one line of an event log. The first line of a log is a "Params" line; every other line is
+// an event, named like its type. The cells flipped between two other events are recorded together on
+// a single "CellsFlipped" line, whether they were sent as CellFlipped or CellsFlipped events. Cells are
+// stored as a flat list of x and y coordinates, and Ms is the time since the recording started.
+type logLine struct {
+	Type           string  `json:"type"`
+	Ms             int64   `json:"ms"`
+	Turn           int     `json:"turn"`
+	Params         *Params `json:"params,omitempty"`
+	Cells          []int   `json:"cells,omitempty"`
+	Count          *int    `json:"count,omitempty"`
+	State          string  `json:"state,omitempty"`
+	Filename       string  `json:"filename,omitempty"`
+	TurnsPerSecond *int    `json:"turnsPerSecond,omitempty"`
+}
+
+// flatCells flattens cells into x and y coordinates.
+func flatCells(cells []util.Cell) []int {
+	flat := make([]int, 0, 2*len(cells))
+	for _, cell := range cells {
+		flat = append(flat, cell.X, cell.Y)
+	}
+	return flat
+}
+
+// unflatCells reverses flatCells.
+func unflatCells(flat []int) []util.Cell {
+	cells := make([]util.Cell, len(flat)/2)
+	for i := range cells {
+		cells[i] = util.Cell{X: flat[2*i], Y: flat[2*i+1]}
+	}
+	return cells
+}
+
+// EventWriter records the events of a run as JSON lines, so that it can be replayed later with
+// Replay. WorkerMetrics events are not recorded, as the timings mean nothing once the run is over.
+type EventWriter struct {
+	out    *bufio.Writer
+	enc    *json.Encoder
+	start  time.Time
+	flips  []int
+	turn   int
+	closer []io.Closer
+}
+
+// NewEventWriter starts a log of a run with the given parameters on w.
+func NewEventWriter(w io.Writer, p Params) (*EventWriter, error) {
+	out := bufio.NewWriter(w)
+	ew := &EventWriter{out: out, enc: json.NewEncoder(out), start: time.Now()}
+	return ew, ew.enc.Encode(logLine{Type: "Params", Params: &p})
+}
+
+// CreateEventLog creates the file at path and starts a log in it. A path ending in .gz is compressed.
+// Closing the writer closes the file.
+func CreateEventLog(path string, p Params) (*EventWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var w io.WriteCloser = file
+	closer := []io.Closer{file}
+	if strings.HasSuffix(path, ".gz") {
+		w = gzip.NewWriter(file)
+		closer = []io.Closer{w, file}
+	}
+	ew, err := NewEventWriter(w, p)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	ew.closer = closer
+	return ew, nil
+}
+
+// Write records an event.
+func (ew *EventWriter) Write(event Event) error {
+	line := logLine{Type: strings.TrimPrefix(fmt.Sprintf("%T", event), "gol."), Turn: event.GetCompletedTurns()}
+	switch e := event.(type) {
+	case CellFlipped, CellsFlipped:
+		// The initial world is loaded at turn 0 and straight away followed by the first turn's cells.
+		if e.GetCompletedTurns() != ew.turn {
+			if err := ew.writeFlips(); err != nil {
+				return err
+			}
+			ew.turn = e.GetCompletedTurns()
+		}
+		if flipped, ok := e.(CellFlipped); ok {
+			ew.flips = append(ew.flips, flipped.Cell.X, flipped.Cell.Y)
+		} else {
+			ew.flips = append(ew.flips, flatCells(e.(CellsFlipped).Cells)...)
+		}
+		return nil
+	case WorkerMetrics:
+		return nil
+	case AliveCellsCount:
+		line.Count = &e.CellsCount
+	case ImageOutputComplete:
+		line.Filename = e.Filename
+	case StateChange:
+		line.State = e.NewState.String()
+	case SpeedChange:
+		line.TurnsPerSecond = &e.TurnsPerSecond
+	case FinalTurnComplete:
+		line.Cells = flatCells(e.Alive)
+		if line.Cells == nil {
+			line.Cells = []int{}
+		}
+	}
+	if err := ew.writeFlips(); err != nil {
+		return err
+	}
+	line.Ms = ew.ms()
+	return ew.enc.Encode(line)
+}
+
+// writeFlips records the cells flipped since the last other event, if there are any.
+func (ew *EventWriter) writeFlips() error {
+	if len(ew.flips) == 0 {
+		return nil
+	}
+	err := ew.enc.Encode(logLine{Type: "CellsFlipped", Ms: ew.ms(), Turn: ew.turn, Cells: ew.flips})
+	ew.flips = ew.flips[:0]
+	return err
+}
+
+func (ew *EventWriter) ms() int64 {
+	return int64(time.Since(ew.start) / time.Millisecond)
+}
+
+// Close flushes the log, and closes the file if it was created by CreateEventLog.
+func (ew *EventWriter) Close() error {
+	err := ew.writeFlips()
+	if flushErr := ew.out.Flush(); err == nil {
+		err = flushErr
+	}
+	for _, c := range ew.closer {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// EventReader reads back a log written by an EventWriter.
+type EventReader struct {
+	// Params are the parameters of the recorded run.
+	Params Params
+
+	dec     *json.Decoder
+	pending []Event
+	at      time.Duration
+	closer  []io.Closer
+}
+
+// NewEventReader reads the start of a log from r, which may be compressed with gzip.
+func NewEventReader(r io.Reader) (*EventReader, error) {
+	in := bufio.NewReader(r)
+	er := &EventReader{}
+	if magic, _ := in.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(in)
+		if err != nil {
+			return nil, err
+		}
+		er.closer = append(er.closer, zr)
+		er.dec = json.NewDecoder(zr)
+	} else {
+		er.dec = json.NewDecoder(in)
+	}
+	var header logLine
+	if err := er.dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("eventlog: %v", err)
+	}
+	if header.Type != "Params" || header.Params == nil {
+		return nil, errors.New("eventlog: missing Params line")
+	}
+	er.Params = *header.Params
+	return er, nil
+}
+
+// OpenEventLog opens the log at path. Closing the reader closes the file.
+func OpenEventLog(path string) (*EventReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	er, err := NewEventReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	er.closer = append(er.closer, file)
+	return er, nil
+}
+
+// Read returns the next event and the time it was sent, measured from the start of the recording. The
+// flipped cells come back in the form the run sent them, as a single CellsFlipped event if BatchFlips was
+// set and as a CellFlipped event per cell otherwise. It returns io.EOF at the end of the log.
+func (er *EventReader) Read() (Event, time.Duration, error) {
+	for len(er.pending) == 0 {
+		var line logLine
+		if err := er.dec.Decode(&line); err == io.EOF {
+			return nil, er.at, io.EOF
+		} else if err != nil {
+			return nil, er.at, fmt.Errorf("eventlog: %v", err)
+		}
+		er.at = time.Duration(line.Ms) * time.Millisecond
+		if err := er.decode(line); err != nil {
+			return nil, er.at, err
+		}
+	}
+	event := er.pending[0]
+	er.pending = er.pending[1:]
+	return event, er.at, nil
+}
+
+// decode turns a line of the log into the events it stands for.
+func (er *EventReader) decode(line logLine) error {
+	switch line.Type {
+	case "CellsFlipped":
+		cells := unflatCells(line.Cells)
+		if er.Params.BatchFlips {
+			er.pending = append(er.pending, CellsFlipped{line.Turn, cells})
+			break
+		}
+		for _, cell := range cells {
+			er.pending = append(er.pending, CellFlipped{line.Turn, cell})
+		}
+	case "TurnComplete":
+		er.pending = append(er.pending, TurnComplete{line.Turn})
+	case "AliveCellsCount":
+		if line.Count == nil {
+			return fmt.Errorf("eventlog: AliveCellsCount at turn %v without a count", line.Turn)
+		}
+		er.pending = append(er.pending, AliveCellsCount{line.Turn, *line.Count})
+	case "ImageOutputComplete":
+		er.pending = append(er.pending, ImageOutputComplete{line.Turn, line.Filename})
+	case "StateChange":
+		state, ok := map[string]State{"Paused": Paused, "Executing": Executing, "Quitting": Quitting}[line.State]
+		if !ok {
+			return fmt.Errorf("eventlog: unknown state %q", line.State)
+		}
+		er.pending = append(er.pending, StateChange{line.Turn, state})
+	case "SpeedChange":
+		if line.TurnsPerSecond == nil {
+			return fmt.Errorf("eventlog: SpeedChange at turn %v without a speed", line.Turn)
+		}
+		er.pending = append(er.pending, SpeedChange{line.Turn, *line.TurnsPerSecond})
+	case "FinalTurnComplete":
+		er.pending = append(er.pending, FinalTurnComplete{line.Turn, unflatCells(line.Cells)})
+	default:
+		return fmt.Errorf("eventlog: unknown event %q", line.Type)
+	}
+	return nil
+}
+
+// Close closes the file if the reader was opened by OpenEventLog.
+func (er *EventReader) Close() error {
+	var err error
+	for _, c := range er.closer {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// RecordEvents writes every event received on events to ew until the channel is closed, then closes ew.
+func RecordEvents(ew *EventWriter, events <-chan Event) error {
+	var err error
+	for event := range events {
+		if err == nil {
+			err = ew.Write(event)
+		}
+	}
+	if closeErr := ew.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Replay sends the events of a log to events, as Run would have, and closes it at the end. A speed of 1
+// keeps the timing of the recording, 2 replays it twice as fast and so on, while 0 sends the events as
+// quickly as they are received. A negative speed is an error. Like a live run, the replay is paused and
+// resumed with 'p', stopped with 'q' or 'k', and sped up or slowed down with '+' and '-'.
+func Replay(er *EventReader, events chan<- Event, keyPresses <-chan rune, speed float64) error {
+	defer close(events)
+	if speed < 0 {
+		return fmt.Errorf("eventlog: the speed cannot be negative, not %v", speed)
+	}
+	clock := replayClock{speed: speed, last: time.Now()}
+	for {
+		event, at, err := er.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !clock.await(at, events, keyPresses) {
+			events <- StateChange{clock.turn, Quitting}
+			return nil
+		}
+		clock.turn = event.GetCompletedTurns()
+		events <- event
+	}
+}
+
+// replayClock keeps the time of a replay, which runs at speed times the real time and stands still
+// while paused.
+type replayClock struct {
+	speed  float64
+	paused bool
+	now    time.Duration
+	last   time.Time
+	turn   int
+}
+
+// await waits until the clock reaches at, acting on any keys pressed in the meantime. It reports false if
+// the replay was stopped.
+func (clock *replayClock) await(at time.Duration, events chan<- Event, keyPresses <-chan rune) bool {
+	for {
+		t := time.Now()
+		if !clock.paused {
+			clock.now += time.Duration(float64(t.Sub(clock.last)) * clock.speed)
+		}
+		clock.last = t
+		if !clock.paused && (clock.speed == 0 || clock.now >= at) {
+			select {
+			case key := <-keyPresses:
+				if !clock.handleKey(key, events) {
+					return false
+				}
+				continue
+			default:
+				return true
+			}
+		}
+
+		wait := 50 * time.Millisecond
+		if !clock.paused {
+			if remaining := time.Duration(float64(at-clock.now) / clock.speed); remaining < wait {
+				wait = remaining
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case key := <-keyPresses:
+			if !clock.handleKey(key, events) {
+				timer.Stop()
+				return false
+			}
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// handleKey acts on a keypress during a replay, reporting false if it stops the replay.
+func (clock *replayClock) handleKey(key rune, events chan<- Event) bool {
+	switch key {
+	case 'q', 'k':
+		return false
+	case 'p':
+		clock.paused = !clock.paused
+		if clock.paused {
+			events <- StateChange{clock.turn, Paused}
+		} else {
+			events <- StateChange{clock.turn, Executing}
+		}
+	case '+':
+		if clock.speed > 0 {
+			clock.speed *= 2
+		}
+	case '-':
+		if clock.speed == 0 {
+			clock.speed = 1
+		} else {
+			clock.speed /= 2
+		}
+	}
+	return true
+}
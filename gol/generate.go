@@ -0,0 +1,168 @@
+package gol
+
+import (
+	"fmt"
+	"math/rand"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Generators are the values of Params.Generator. random is a soup with no symmetry, and c2, c4 and d8
+// are soups that look the same after a half turn, a quarter turn, and a quarter turn or a reflection,
+// as in apgsearch. noise repeats a random tile across the world, and pattern places PatternFile in the
+// middle of it.
+var Generators = []string{"random", "c2", "c4", "d8", "noise", "pattern"}
+
+// defaultNoiseTile is the side of a noise tile when SoupSize is zero.
+const defaultNoiseTile = 16
+
+// validateGenerator reports generator parameters that cannot make a world of the size in p.
+func (p Params) validateGenerator() error {
+	if p.Generator == "" {
+		return nil
+	}
+	known := false
+	for _, generator := range Generators {
+		known = known || p.Generator == generator
+	}
+	switch {
+	case !known:
+		return fmt.Errorf("gol: unknown generator %q, expected one of %v", p.Generator, Generators)
+	case p.Density < 0 || p.Density > 1:
+		return fmt.Errorf("gol: the density must be between 0 and 1, not %v", p.Density)
+	case p.SoupSize < 0:
+		return fmt.Errorf("gol: the soup size cannot be negative, not %v", p.SoupSize)
+	case p.Generator == "pattern" && p.PatternFile == "":
+		return fmt.Errorf("gol: the pattern generator needs a PatternFile")
+	case p.Generator != "noise" && p.Generator != "pattern" &&
+		(p.SoupSize > p.ImageWidth || p.SoupSize > p.ImageHeight):
+		return fmt.Errorf("gol: a %vx%[1]v soup does not fit in a %vx%v world", p.SoupSize, p.ImageWidth, p.ImageHeight)
+	}
+	return nil
+}
+
+// Generated describes how the initial world was made, such as "c4 seed=42 density=0.5 size=16", or
+// returns an empty string if it was read from an image.
+func (p Params) Generated() string {
+	switch p.Generator {
+	case "":
+		return ""
+	case "pattern":
+		return fmt.Sprintf("pattern %v", p.PatternFile)
+	}
+	s := fmt.Sprintf("%v seed=%v density=%v", p.Generator, p.Seed, p.density())
+	if p.SoupSize > 0 {
+		s += fmt.Sprintf(" size=%v", p.SoupSize)
+	}
+	return s
+}
+
+func (p Params) density() float64 {
+	if p.Density == 0 {
+		return 0.5
+	}
+	return p.Density
+}
+
+// Generate makes the initial world described by Generator, indexed [y][x], in which 255 is alive and
+// 0 is dead.
+func Generate(p Params) ([][]byte, error) {
+	if p.ImageWidth <= 0 || p.ImageHeight <= 0 {
+		return nil, fmt.Errorf("gol: the world must have a positive size, not %vx%v", p.ImageWidth, p.ImageHeight)
+	}
+	if p.Generator == "" {
+		return nil, fmt.Errorf("gol: there is no generator to make the world with")
+	}
+	if err := p.validateGenerator(); err != nil {
+		return nil, err
+	}
+	if p.Generator == "pattern" {
+		pattern, err := util.ReadRLE(p.PatternFile)
+		if err != nil {
+			return nil, err
+		}
+		return pattern.Centred(p.ImageWidth, p.ImageHeight)
+	}
+
+	rng := rand.New(rand.NewSource(p.Seed))
+	alive := func() byte {
+		if rng.Float64() < p.density() {
+			return 255
+		}
+		return 0
+	}
+	world := makeWorld(p.ImageHeight, p.ImageWidth)
+
+	if p.Generator == "noise" {
+		side := p.SoupSize
+		if side == 0 {
+			side = defaultNoiseTile
+		}
+		tile := makeWorld(side, side)
+		for y := range tile {
+			for x := range tile[y] {
+				tile[y][x] = alive()
+			}
+		}
+		for y := range world {
+			for x := range world[y] {
+				world[y][x] = tile[y%side][x%side]
+			}
+		}
+		return world, nil
+	}
+
+	width, height := p.ImageWidth, p.ImageHeight
+	if p.Generator == "c4" || p.Generator == "d8" {
+		if width > height {
+			width = height
+		}
+		height = width
+	}
+	if p.SoupSize > 0 {
+		width, height = p.SoupSize, p.SoupSize
+	}
+	left, top := (p.ImageWidth-width)/2, (p.ImageHeight-height)/2
+	// Cells are drawn in order, and every other cell of an orbit under the symmetry copies the first.
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			first := orbitStart(p.Generator, x, y, width, height)
+			if first == (util.Cell{X: x, Y: y}) {
+				world[top+y][left+x] = alive()
+			} else {
+				world[top+y][left+x] = world[top+first.Y][left+first.X]
+			}
+		}
+	}
+	return world, nil
+}
+
+// orbitStart returns the first cell, in row major order, of the cells that the symmetry of a soup maps
+// the cell at x, y to. A square soup is needed for c4 and d8.
+func orbitStart(generator string, x, y, width, height int) util.Cell {
+	// An orbit has at most eight cells, so it is kept on the stack rather than allocated for every cell.
+	var orbit [8]util.Cell
+	orbit[0] = util.Cell{X: x, Y: y}
+	n := 1
+	switch generator {
+	case "c2":
+		orbit[1] = util.Cell{X: width - 1 - x, Y: height - 1 - y}
+		n = 2
+	case "c4", "d8":
+		for ; n < 4; n++ {
+			orbit[n] = util.Cell{X: width - 1 - orbit[n-1].Y, Y: orbit[n-1].X}
+		}
+		if generator == "d8" {
+			for ; n < 8; n++ {
+				orbit[n] = util.Cell{X: orbit[n-4].Y, Y: orbit[n-4].X}
+			}
+		}
+	}
+	first := orbit[0]
+	for _, cell := range orbit[1:n] {
+		if cell.Y < first.Y || cell.Y == first.Y && cell.X < first.X {
+			first = cell
+		}
+	}
+	return first
+}
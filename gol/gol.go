@@ -1,37 +1,281 @@
 package gol
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// logger prints the messages of a run that are not events, such as "File 16x16 input done!".
+var logger = log.New(os.Stdout, "", 0)
+
+// SetOutput sets where runs print the messages that are not events, which is os.Stdout to begin with,
+// and returns where they went before. A view that draws on the terminal should send them elsewhere
+// while it is drawing, so that they do not break up its frames.
+func SetOutput(w io.Writer) io.Writer {
+	previous := logger.Writer()
+	logger.SetOutput(w)
+	return previous
+}
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	// TileHeight switches the distributor from one equal strip per worker to tiles of this many rows
+	// pulled from a shared queue, so that faster workers pick up the slack. Zero keeps the static split.
+	TileHeight int
+	// BlockRows and BlockCols split the world into a 2D grid of blocks, each computed from a copy with a
+	// halo on all four sides. The blocks are dealt out to the Threads workers in turn. They take priority
+	// over TileHeight, must be set together and cannot be more than the rows and columns of the world.
+	BlockRows int
+	BlockCols int
+	// HaloDepth is the number of generations workers advance locally between synchronisations. Each
+	// worker copies a halo this deep around its part of the world, trading redundant work at the edges
+	// for fewer barriers. TurnComplete and CellFlipped are still sent for every turn. Zero means one, and
+	// Clamp limits it to the size of the smallest part of the world.
+	HaloDepth int
+	// TurnsPerSecond is the initial target speed, which '+' and '-' change while running. Zero means unthrottled,
+	// and it can be at most 1024.
+	TurnsPerSecond int
+	// History is the number of recent turns kept so that 'b' can step back through them while paused.
+	// Zero disables stepping back.
+	History int
+	// Metrics enables the per-turn WorkerMetrics event. Workers do not read the clock when it is disabled.
+	Metrics bool
+	// BatchFlips sends the cells flipped by each turn, edit or load as a single CellsFlipped event
+	// instead of a CellFlipped event per cell.
+	BatchFlips bool
+	// ImageDir is the directory the input image is read from, and OutDir the one images and population
+	// series are saved to. Empty means images and out, relative to the working directory.
+	ImageDir string
+	OutDir   string
+	// Generator makes the initial world instead of reading it from ImageDir. It is one of Generators,
+	// or empty to read the image. The same Seed and parameters always make the same world, and each image
+	// saved from it has a JSON file of the same name next to it recording how it was made.
+	Generator string
+	Seed      int64
+	// Density is the chance that each cell of a soup or noise is alive. Zero means one half.
+	Density float64
+	// SoupSize is the side of the square soup placed in the middle of an otherwise empty world, or of
+	// the noise tile repeated across it. Zero fills the whole world with soup, or the largest square that
+	// fits for C4 and D8 symmetry, and makes 16x16 noise tiles.
+	SoupSize int
+	// PatternFile is the RLE file placed in the middle of the world by the pattern generator.
+	PatternFile string
+}
+
+// imageDir returns the directory the input image is read from.
+func (p Params) imageDir() string {
+	if p.ImageDir == "" {
+		return "images"
+	}
+	return p.ImageDir
+}
+
+// outDir returns the directory output is saved to.
+func (p Params) outDir() string {
+	if p.OutDir == "" {
+		return "out"
+	}
+	return p.OutDir
+}
+
+// Validate reports the first parameter that the Game of Life cannot run with. More threads than there
+// is work to split between them are not an error, see Clamp.
+func (p Params) Validate() error {
+	switch {
+	case p.Threads <= 0:
+		return fmt.Errorf("gol: there must be at least one thread, not %v", p.Threads)
+	case p.ImageWidth <= 0 || p.ImageHeight <= 0:
+		return fmt.Errorf("gol: the world must have a positive size, not %vx%v", p.ImageWidth, p.ImageHeight)
+	case p.Turns < 0:
+		return fmt.Errorf("gol: the number of turns cannot be negative, not %v", p.Turns)
+	case p.TileHeight < 0 || p.BlockRows < 0 || p.BlockCols < 0 || p.HaloDepth < 0:
+		return errors.New("gol: TileHeight, BlockRows, BlockCols and HaloDepth cannot be negative")
+	case (p.BlockRows > 0) != (p.BlockCols > 0):
+		return fmt.Errorf("gol: BlockRows and BlockCols must be set together, not %v and %v", p.BlockRows, p.BlockCols)
+	case p.BlockRows > p.ImageHeight || p.BlockCols > p.ImageWidth:
+		return fmt.Errorf("gol: a %vx%v grid of blocks does not fit in a %vx%v world",
+			p.BlockCols, p.BlockRows, p.ImageWidth, p.ImageHeight)
+	case p.TurnsPerSecond < 0:
+		return fmt.Errorf("gol: the target speed cannot be negative, not %v", p.TurnsPerSecond)
+	case p.TurnsPerSecond > maxTurnsPerSecond:
+		return fmt.Errorf("gol: the target speed cannot be more than %v turns/s, not %v; use 0 for unthrottled",
+			maxTurnsPerSecond, p.TurnsPerSecond)
+	case p.History < 0:
+		return fmt.Errorf("gol: the history cannot be negative, not %v", p.History)
+	}
+	return p.validateGenerator()
+}
+
+// Clamp returns p with Threads reduced to the number of strips, tiles or blocks the world is split into,
+// which for strips is one per row, and HaloDepth reduced to the height or width of the smallest of them,
+// along with a warning for each. The warning is empty if p is returned as it is. Run and New clamp their
+// params themselves, so this is only needed to know the number of threads a run will really use.
+func (p Params) Clamp() (Params, string) {
+	var warnings []string
+	pieces := p.ImageHeight
+	if p.BlockRows > 0 && p.BlockCols > 0 {
+		pieces = len(blockGrid(p))
+	} else if p.TileHeight > 0 {
+		pieces = len(tileBlocks(p))
+	}
+	if p.Threads > pieces && pieces > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"gol: %v threads is more than the %v parts the world is split into, so only %v are used",
+			p.Threads, pieces, pieces))
+		p.Threads = pieces
+	}
+	if depth := smallestPart(p); p.HaloDepth > depth && depth > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"gol: a halo depth of %v is more than the %v cells across the smallest part of the world, so %v is used",
+			p.HaloDepth, depth, depth))
+		p.HaloDepth = depth
+	}
+	return p, strings.Join(warnings, "\n")
+}
+
+// SquareGrid returns the most square rows x cols grid with exactly n blocks, with rows <= cols.
+// A prime n gives a 1 x n grid.
+func SquareGrid(n int) (rows, cols int) {
+	rows = 1
+	for r := 1; r*r <= n; r++ {
+		if n%r == 0 {
+			rows = r
+		}
+	}
+	return rows, n / rows
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
+// If p is not valid, the reason is printed and events is closed straight away.
 func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+	RunWithEdits(p, events, keyPresses, nil)
+}
+
+// RunWithEdits is Run with an extra channel of cells to toggle while the run is paused.
+// Every edit is confirmed with a CellFlipped event, or a CellsFlipped event if BatchFlips is set,
+// followed by a TurnComplete for the current turn.
+func RunWithEdits(p Params, events chan<- Event, keyPresses <-chan rune, edits <-chan util.Cell) {
+	if err := RunContext(context.Background(), p, events, keyPresses, edits); err != nil {
+		logger.Println(err)
+	}
+}
+
+// RunContext is RunWithEdits that can also be stopped by cancelling ctx. Whether the run completes or is
+// cancelled, every goroutine it started has returned and events has been closed by the time it returns.
+// A cancelled run stops between turns without saving the final world or sending FinalTurnComplete,
+// drops any events that are not received, and returns ctx.Err(). If p is not valid or the image cannot be
+// read, it closes events and returns the error without starting. If an image cannot be saved, the run
+// stops there without sending FinalTurnComplete, and returns the error.
+func RunContext(ctx context.Context, p Params, events chan<- Event, keyPresses <-chan rune, edits <-chan util.Cell) error {
+	return RunWorld(ctx, p, nil, events, keyPresses, edits)
+}
 
-	//	TODO: Put the missing channels in here.
+// RunWorld is RunContext starting from the given world, indexed [y][x], instead of the image in images/.
+// A nil world is made by p's Generator if it has one, and read from the image otherwise. It returns an
+// error straight away if the world does not match the size in p, p is not valid, the generator fails or
+// the image cannot be read, and prints a warning if it has more threads than it can use.
+func RunWorld(ctx context.Context, p Params, world [][]byte, events chan<- Event, keyPresses <-chan rune, edits <-chan util.Cell) error {
+	if err := p.Validate(); err != nil {
+		close(events)
+		return err
+	}
+	p, warning := p.Clamp()
+	if warning != "" {
+		logger.Println(warning)
+	}
+	if world == nil && p.Generator != "" {
+		var err error
+		if world, err = Generate(p); err != nil {
+			close(events)
+			return err
+		}
+	} else if world != nil {
+		if err := checkWorld(p, world); err != nil {
+			close(events)
+			return err
+		}
+	}
 
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
+	ioFilename := make(chan string)
+	ioOutput := make(chan uint8)
+	ioOutputErr := make(chan error)
+	ioInput := make(chan uint8)
+	ioInputErr := make(chan error)
 
 	ioChannels := ioChannels{
-		command:  ioCommand,
-		idle:     ioIdle,
-		filename: nil,
-		output:   nil,
-		input:    nil,
+		command:   ioCommand,
+		idle:      ioIdle,
+		filename:  ioFilename,
+		output:    ioOutput,
+		outputErr: ioOutputErr,
+		input:     ioInput,
+		inputErr:  ioInputErr,
 	}
 	go startIo(p, ioChannels)
 
 	distributorChannels := distributorChannels{
-		events:     events,
-		ioCommand:  ioCommand,
-		ioIdle:     ioIdle,
-		ioFilename: nil,
-		ioOutput:   nil,
-		ioInput:    nil,
-	}
-	distributor(p, distributorChannels)
+		events:      &emitter{listeners: []*listener{{events: events}}},
+		ioCommand:   ioCommand,
+		ioIdle:      ioIdle,
+		ioFilename:  ioFilename,
+		ioOutput:    ioOutput,
+		ioOutputErr: ioOutputErr,
+		ioInput:     ioInput,
+		ioInputErr:  ioInputErr,
+		keyPresses:  keyPresses,
+		edits:       edits,
+	}
+	if world == nil {
+		var err error
+		if world, err = readWorld(p, distributorChannels); err == nil {
+			err = checkWorld(p, world)
+		}
+		if err != nil {
+			ioCommand <- ioQuit
+			close(events)
+			return err
+		}
+	}
+	engine := newEngine(p, distributorChannels)
+
+	// Cancelling closes the engine, which ends the Step below between turns.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			engine.Close()
+		case <-finished:
+		}
+	}()
+
+	// The world has been checked, so Load only fails if ctx has already closed the engine, in which case
+	// Step returns straight away as well.
+	_ = engine.Load(world)
+	engine.Step(p.Turns)
+	err := engine.failure()
+	if err == nil && ctx.Err() == nil {
+		err = engine.finish()
+	}
+	// Closing the engine closes events, which stops the SDL goroutine gracefully.
+	engine.Close()
+	// The run is over, so the io goroutine is told to return as well.
+	ioCommand <- ioQuit
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
 }
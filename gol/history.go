@@ -0,0 +1,42 @@
+package gol
+
+// history is a bounded ring of the cells flipped by each of the most recent turns.
+// Cells are stored as y*width+x so that long histories of large worlds stay compact.
+type history struct {
+	diffs [][]int32
+	head  int
+	count int
+}
+
+func newHistory(length int) *history {
+	return &history{diffs: make([][]int32, length)}
+}
+
+// push records the cells flipped by a turn, forgetting the oldest turn once the ring is full.
+// The slice is reused by a later push, so the caller must not keep it.
+func (h *history) push(flipped []int32) {
+	if len(h.diffs) == 0 {
+		return
+	}
+	h.diffs[h.head] = append(h.diffs[h.head][:0], flipped...)
+	h.head = (h.head + 1) % len(h.diffs)
+	if h.count < len(h.diffs) {
+		h.count++
+	}
+}
+
+// pop removes and returns the cells flipped by the most recent turn still in the ring.
+func (h *history) pop() ([]int32, bool) {
+	if h.count == 0 {
+		return nil, false
+	}
+	h.head = (h.head - 1 + len(h.diffs)) % len(h.diffs)
+	h.count--
+	return h.diffs[h.head], true
+}
+
+// clear forgets every turn in the ring.
+func (h *history) clear() {
+	h.head = 0
+	h.count = 0
+}
@@ -1,10 +1,11 @@
 package gol
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -16,6 +17,10 @@ type ioChannels struct {
 	filename <-chan string
 	output   <-chan uint8
 	input    chan<- uint8
+	// outputErr receives whether the image could be written, once all of its bytes have been received.
+	outputErr chan<- error
+	// inputErr receives whether the image could be read, before any of its bytes are sent on input.
+	inputErr chan<- error
 }
 
 // ioState is the internal ioState of the io goroutine.
@@ -32,32 +37,20 @@ type ioCommand uint8
 //		ioOutput 	= 0
 //		ioInput 	= 1
 //		ioCheckIdle = 2
+//		ioQuit		= 3
 const (
 	ioOutput ioCommand = iota
 	ioInput
 	ioCheckIdle
+	ioQuit
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
+// writePgmImage receives an array of bytes and writes it to a pgm file, then sends whether it could be
+// written. Every byte is received even if the file cannot be created, so the distributor is never left waiting.
 func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
-
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
 
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
-	defer file.Close()
-
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
-
 	world := make([][]byte, io.params.ImageHeight)
 	for i := range world {
 		world[i] = make([]byte, io.params.ImageWidth)
@@ -65,64 +58,133 @@ func (io *ioState) writePgmImage() {
 
 	for y := 0; y < io.params.ImageHeight; y++ {
 		for x := 0; x < io.params.ImageWidth; x++ {
-			val := <-io.channels.output
-			//if val != 0 {
-			//	fmt.Println(x, y)
-			//}
-			world[y][x] = val
+			world[y][x] = <-io.channels.output
 		}
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
+	ioError := io.params.writeImage(filename, world)
+	io.channels.outputErr <- ioError
+	if ioError != nil {
+		return
+	}
+
+	// The image has been saved, so failing to save how its world was made is not worth ending the run over.
+	if io.params.Generator != "" {
+		if err := io.params.writeOrigin(filename); err != nil {
+			logger.Println("Could not save how", filename, "was generated:", err)
 		}
 	}
 
-	ioError = file.Sync()
-	util.Check(ioError)
+	logger.Println("File", filename, "output done!")
+}
 
-	fmt.Println("File", filename, "output done!")
+// writeImage saves world as filename.pgm in the output directory.
+func (p Params) writeImage(filename string, world [][]byte) error {
+	if err := os.MkdirAll(p.outDir(), os.ModePerm); err != nil {
+		return fmt.Errorf("gol: %v", err)
+	}
+	path := filepath.Join(p.outDir(), filename+".pgm")
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("gol: %v", err)
+	}
+	err = util.WritePGM(file, world)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("gol: %v: %v", path, err)
+	}
+	return nil
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
-func (io *ioState) readPgmImage() {
+// readImage reads the pgm file called filename from the image directory and checks that it is the size
+// of the world.
+func (p Params) readImage(filename string) ([][]byte, error) {
+	path := filepath.Join(p.imageDir(), filename+".pgm")
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		var sizes []string
+		images, _ := filepath.Glob(filepath.Join(p.imageDir(), "*x*.pgm"))
+		for _, image := range images {
+			sizes = append(sizes, strings.TrimSuffix(filepath.Base(image), ".pgm"))
+		}
+		return nil, fmt.Errorf("gol: there is no %v image in %v, the sizes there are %v", filename, p.imageDir(), sizes)
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 
-	// Request a filename from the distributor.
-	filename := <-io.channels.filename
+	world, err := util.ParsePGM(file)
+	if err != nil {
+		return nil, fmt.Errorf("gol: %v: %v", path, err)
+	}
+	if len(world) != p.ImageHeight || len(world[0]) != p.ImageWidth {
+		return nil, fmt.Errorf("gol: %v is %vx%v, not %vx%v", path, len(world[0]), len(world), p.ImageWidth, p.ImageHeight)
+	}
+	return world, nil
+}
 
-	data, ioError := ioutil.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
+// CheckImage reports an error if the image that a run without a world or Generator starts from is
+// missing, cannot be read or is not ImageWidth x ImageHeight.
+func (p Params) CheckImage() error {
+	_, err := p.readImage(fmt.Sprintf("%dx%d", p.ImageWidth, p.ImageHeight))
+	return err
+}
 
-	fields := strings.Fields(string(data))
+// worldOrigin is saved as JSON next to every image of a generated world, so that the world it started
+// from can be made again. The image itself is left as a plain PGM, as not every reader skips comments.
+type worldOrigin struct {
+	Generated   string  `json:"generated"`
+	Generator   string  `json:"generator"`
+	Seed        int64   `json:"seed"`
+	Density     float64 `json:"density"`
+	SoupSize    int     `json:"soupSize,omitempty"`
+	PatternFile string  `json:"patternFile,omitempty"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+}
 
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+// writeOrigin saves how the world was generated to filename.json in the output directory.
+func (p Params) writeOrigin(filename string) error {
+	data, err := json.MarshalIndent(worldOrigin{
+		Generated:   p.Generated(),
+		Generator:   p.Generator,
+		Seed:        p.Seed,
+		Density:     p.density(),
+		SoupSize:    p.SoupSize,
+		PatternFile: p.PatternFile,
+		Width:       p.ImageWidth,
+		Height:      p.ImageHeight,
+	}, "", "\t")
+	if err != nil {
+		return err
 	}
+	return ioutil.WriteFile(filepath.Join(p.outDir(), filename+".json"), append(data, '\n'), 0644)
+}
 
-	width, _ := strconv.Atoi(fields[1])
-	if width != io.params.ImageWidth {
-		panic("Incorrect width")
-	}
+// readPgmImage opens a pgm file and sends its data as an array of bytes, or the reason it cannot.
+func (io *ioState) readPgmImage() {
 
-	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
-	}
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
 
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+	world, ioError := io.params.readImage(filename)
+	io.channels.inputErr <- ioError
+	if ioError != nil {
+		return
 	}
 
-	image := []byte(fields[4])
-
-	for _, b := range image {
-		io.channels.input <- b
+	for _, row := range world {
+		for _, b := range row {
+			io.channels.input <- b
+		}
 	}
 
-	fmt.Println("File", filename, "input done!")
+	logger.Println("File", filename, "input done!")
 }
 
 // startIo should be the entrypoint of the io goroutine.
@@ -143,6 +205,8 @@ func startIo(p Params, c ioChannels) {
 				io.writePgmImage()
 			case ioCheckIdle:
 				io.channels.idle <- true
+			case ioQuit:
+				return
 			}
 		}
 	}
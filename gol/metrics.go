@@ -0,0 +1,74 @@
+package gol
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// metricsInterval is how often a rolling summary is considered due.
+const metricsInterval = 2 * time.Second
+
+// MetricsSummary accumulates WorkerMetrics events into a rolling per-worker summary.
+// The zero value is ready to use.
+type MetricsSummary struct {
+	firstTurn int
+	lastTurn  int
+	turns     int
+	latency   time.Duration
+	workers   []WorkerTiming
+	since     time.Time
+}
+
+// Add folds the timings of one event into the summary and reports whether a summary is due. An event
+// covering several turns counts as that many, so the means in the summary stay per turn.
+func (s *MetricsSummary) Add(m WorkerMetrics) bool {
+	if s.since.IsZero() {
+		s.since = time.Now()
+	}
+	turns := m.Turns
+	if turns < 1 {
+		turns = 1
+	}
+	if s.turns == 0 {
+		s.firstTurn = m.CompletedTurns - turns + 1
+	}
+	s.lastTurn = m.CompletedTurns
+	s.turns += turns
+	s.latency += m.Latency
+	if len(s.workers) != len(m.Workers) {
+		s.workers = make([]WorkerTiming, len(m.Workers))
+	}
+	for i, w := range m.Workers {
+		s.workers[i].Worker = w.Worker
+		s.workers[i].Rows = w.Rows
+		s.workers[i].Cells = w.Cells
+		s.workers[i].Compute += w.Compute
+		s.workers[i].Wait += w.Wait
+	}
+	return time.Since(s.since) >= metricsInterval
+}
+
+// Flush returns the summary of every turn added since the last flush and starts a new window.
+func (s *MetricsSummary) Flush() string {
+	if s.turns == 0 {
+		return ""
+	}
+	n := time.Duration(s.turns)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Turns %v-%v: %v turns, mean turn latency %v\n", s.firstTurn, s.lastTurn, s.turns, s.latency/n)
+	fmt.Fprintf(&b, "  %-8v%-8v%-10v%-14v%-14v\n", "Worker", "Rows", "Cells", "Compute", "Wait")
+	straggler := 0
+	for i, w := range s.workers {
+		fmt.Fprintf(&b, "  %-8v%-8v%-10v%-14v%-14v\n", w.Worker, w.Rows, w.Cells, w.Compute/n, w.Wait/n)
+		if w.Compute > s.workers[straggler].Compute {
+			straggler = i
+		}
+	}
+	if len(s.workers) > 0 {
+		fmt.Fprintf(&b, "  Slowest worker: %v\n", s.workers[straggler].Worker)
+	}
+
+	*s = MetricsSummary{since: time.Now()}
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package gol
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// workerPool keeps p.Threads workers alive for the whole run and a fixed set of preallocated worlds.
+// buffers[0] is always the current world. During a step the workers write generation g into
+// buffers[g], and afterwards the buffers are rotated so that the newest generation is at the front.
+// The workers and the distributor only meet at the barrier, so the worlds need no locking: the
+// distributor may read any buffer while the workers are parked between steps.
+type workerPool struct {
+	p           Params
+	buffers     [][][]byte
+	generations [][][]byte
+	steps       int
+
+	// assigned holds each worker's fixed share of the world, or nil when tiles are used instead.
+	assigned [][]block
+	tiles    []block
+	nextTile int32
+
+	barrier *barrier
+	results []workerResult
+	stopped bool
+}
+
+// newWorkerPool allocates every world the run will need, copies world into the front buffer and
+// starts the workers.
+func newWorkerPool(p Params, world [][]byte) *workerPool {
+	depth := p.HaloDepth
+	if depth < 1 {
+		depth = 1
+	}
+	pool := &workerPool{
+		p:           p,
+		buffers:     make([][][]byte, depth+1),
+		generations: make([][][]byte, depth),
+		barrier:     newBarrier(p.Threads + 1),
+		results:     make([]workerResult, p.Threads),
+	}
+	for i := range pool.buffers {
+		pool.buffers[i] = makeWorld(p.ImageHeight, p.ImageWidth)
+	}
+	pool.load(world)
+
+	grid := p.BlockRows > 0 && p.BlockCols > 0
+	var blocks []block
+	if p.TileHeight > 0 && !grid {
+		blocks = tileBlocks(p)
+		pool.tiles = blocks
+	} else {
+		if grid {
+			blocks = blockGrid(p)
+		} else {
+			blocks = stripBlocks(p)
+		}
+		pool.assigned = make([][]block, p.Threads)
+		for i, b := range blocks {
+			pool.assigned[i%p.Threads] = append(pool.assigned[i%p.Threads], b)
+		}
+	}
+
+	// Only blocks computed through a halo need scratch space, which is sized for the largest of them.
+	maxHeight, maxWidth := 0, 0
+	for _, b := range blocks {
+		if b.endY-b.startY > maxHeight {
+			maxHeight = b.endY - b.startY
+		}
+		if b.endX-b.startX > maxWidth {
+			maxWidth = b.endX - b.startX
+		}
+	}
+	for i := 0; i < p.Threads; i++ {
+		var s *scratch
+		if depth > 1 || grid {
+			s = newScratch(maxHeight+2*depth, maxWidth+2*depth)
+		}
+		go pool.worker(i, s)
+	}
+	return pool
+}
+
+// world returns the current world. It must only be used between steps.
+func (pool *workerPool) world() [][]byte {
+	return pool.buffers[0]
+}
+
+// load replaces the current world. It must only be used between steps.
+func (pool *workerPool) load(world [][]byte) {
+	for y := range world {
+		copy(pool.buffers[0][y], world[y])
+	}
+}
+
+// step advances the world by steps generations and returns each of them, oldest first.
+// The returned worlds stay valid until the next call to step.
+func (pool *workerPool) step(steps int) ([][][]byte, []workerResult) {
+	pool.steps = steps
+	atomic.StoreInt32(&pool.nextTile, 0)
+
+	// Release the workers, then wait for all of them to finish.
+	pool.barrier.wait()
+	pool.barrier.wait()
+
+	generations := pool.generations[:steps]
+	copy(generations, pool.buffers[1:steps+1])
+
+	// Rotate the newest generation to the front; the old front becomes a back buffer again.
+	newest := pool.buffers[steps]
+	copy(pool.buffers[1:steps+1], pool.buffers[:steps])
+	pool.buffers[0] = newest
+
+	return generations, pool.results
+}
+
+// stop releases the workers for the last time so that they can return.
+func (pool *workerPool) stop() {
+	pool.stopped = true
+	pool.barrier.wait()
+}
+
+// claim returns the next block for worker i, either from its fixed share or from the shared tiles.
+func (pool *workerPool) claim(i, claimed int) (block, bool) {
+	if pool.assigned != nil {
+		if claimed < len(pool.assigned[i]) {
+			return pool.assigned[i][claimed], true
+		}
+		return block{}, false
+	}
+	next := int(atomic.AddInt32(&pool.nextTile, 1)) - 1
+	if next < len(pool.tiles) {
+		return pool.tiles[next], true
+	}
+	return block{}, false
+}
+
+// worker computes its blocks every step until the pool is stopped.
+// The clock is only read when metrics are enabled so that instrumentation is free otherwise.
+func (pool *workerPool) worker(i int, s *scratch) {
+	p := pool.p
+	for {
+		pool.barrier.wait()
+		if pool.stopped {
+			return
+		}
+
+		var result workerResult
+		if p.Metrics {
+			result.started = time.Now()
+		}
+		world := pool.buffers[0]
+		generations := pool.buffers[1 : pool.steps+1]
+		for claimed := 0; ; claimed++ {
+			b, ok := pool.claim(i, claimed)
+			if !ok {
+				break
+			}
+			if len(generations) == 1 && b.startX == 0 && b.endX == p.ImageWidth {
+				calculateNextRows(world, generations[0][b.startY:b.endY], b.startY, p.ImageWidth, p.ImageHeight)
+			} else {
+				calculateBlock(p, world, generations, b, s)
+			}
+			result.rowCount += b.endY - b.startY
+			result.cellCount += (b.endY - b.startY) * (b.endX - b.startX)
+		}
+		if p.Metrics {
+			result.finished = time.Now()
+		}
+		pool.results[i] = result
+
+		pool.barrier.wait()
+	}
+}
@@ -0,0 +1,55 @@
+package gol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Population records the number of alive cells after every turn, as listed in check/alive.
+// The zero value is ready to use.
+type Population struct {
+	// counts[i] is the number of alive cells after turn i+1.
+	counts []int32
+}
+
+// Record sets the number of alive cells after a completed turn. Recording a turn that has already
+// been recorded, as happens after stepping back or editing cells, forgets every turn after it.
+// Turn 0 is the initial world and is not part of the series.
+func (pop *Population) Record(turn, alive int) {
+	if turn < 1 || turn > len(pop.counts)+1 {
+		return
+	}
+	pop.counts = append(pop.counts[:turn-1], int32(alive))
+}
+
+// Counts returns the recorded series, where element i is the number of alive cells after turn i+1.
+// It must not be modified, and is only valid until the next call to Record.
+func (pop *Population) Counts() []int32 {
+	return pop.counts
+}
+
+// WriteCSV writes the series in the same format as the files in check/alive.
+func (pop *Population) WriteCSV(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "completed_turns,alive_cells")
+	for i, alive := range pop.counts {
+		fmt.Fprintf(out, "%v,%v\n", i+1, alive)
+	}
+	return out.Flush()
+}
+
+// Save writes the series to the output directory, named like the image of the final turn, and returns
+// the path.
+func (pop *Population) Save(p Params) (string, error) {
+	_ = os.MkdirAll(p.outDir(), os.ModePerm)
+	path := filepath.Join(p.outDir(), fmt.Sprintf("%vx%vx%v.csv", p.ImageWidth, p.ImageHeight, len(pop.counts)))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return path, pop.WriteCSV(file)
+}
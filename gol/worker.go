@@ -0,0 +1,210 @@
+package gol
+
+import (
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// workerResult is what a worker reports to the distributor once its share of a step is done.
+type workerResult struct {
+	rowCount  int
+	cellCount int
+	started   time.Time
+	finished  time.Time
+}
+
+// makeWorld allocates an empty height x width world.
+func makeWorld(height, width int) [][]byte {
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+	}
+	return world
+}
+
+// Rule is the rule that nextCell applies, in B/S notation: a cell is born with 3 neighbours and
+// survives with 2 or 3.
+const Rule = "B3/S23"
+
+// nextCell applies the Game of Life rules to a single cell.
+func nextCell(alive bool, neighbours int) byte {
+	if neighbours == 3 || (alive && neighbours == 2) {
+		return 255
+	}
+	return 0
+}
+
+// calculateNextRows computes the next state of the rows starting at startY on the closed (wrapping) domain.
+// One row is computed for every row of the rows slice, which may be a window onto a shared world.
+func calculateNextRows(world, rows [][]byte, startY, width, height int) {
+	for y := startY; y < startY+len(rows); y++ {
+		up := world[(y-1+height)%height]
+		mid := world[y]
+		down := world[(y+1)%height]
+		for x := 0; x < width; x++ {
+			left := (x - 1 + width) % width
+			right := (x + 1) % width
+			sum := int(up[left]) + int(up[x]) + int(up[right]) +
+				int(mid[left]) + int(mid[right]) +
+				int(down[left]) + int(down[x]) + int(down[right])
+			rows[y-startY][x] = nextCell(mid[x] == 255, sum/255)
+		}
+	}
+}
+
+// calculateAliveCells returns the coordinates of every alive cell in the world.
+func calculateAliveCells(p Params, world [][]byte) []util.Cell {
+	var cells []util.Cell
+	for y := 0; y < p.ImageHeight; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			if world[y][x] == 255 {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return cells
+}
+
+// block is one rectangle of a 2D decomposition of the world, covering rows [startY, endY) and
+// columns [startX, endX).
+type block struct {
+	startY, endY int
+	startX, endX int
+}
+
+// blockGrid splits the world into BlockRows x BlockCols blocks, skipping any that would be empty.
+func blockGrid(p Params) []block {
+	var blocks []block
+	for r := 0; r < p.BlockRows; r++ {
+		for c := 0; c < p.BlockCols; c++ {
+			b := block{
+				startY: r * p.ImageHeight / p.BlockRows,
+				endY:   (r + 1) * p.ImageHeight / p.BlockRows,
+				startX: c * p.ImageWidth / p.BlockCols,
+				endX:   (c + 1) * p.ImageWidth / p.BlockCols,
+			}
+			if b.endY > b.startY && b.endX > b.startX {
+				blocks = append(blocks, b)
+			}
+		}
+	}
+	return blocks
+}
+
+// scratch is the private space a worker copies its blocks into, allocated once for the largest block.
+// rows holds views onto the cells so that smaller blocks can be described without allocating.
+type scratch struct {
+	local, next         [][]byte
+	localRows, nextRows [][]byte
+}
+
+func newScratch(height, width int) *scratch {
+	return &scratch{
+		local:     makeWorld(height, width),
+		next:      makeWorld(height, width),
+		localRows: make([][]byte, height),
+		nextRows:  make([][]byte, height),
+	}
+}
+
+// haloBlock copies a block out of the world into the scratch space together with a halo of depth cells
+// on all four sides, wrapping around the edges of the world. Cell (x, y) of the block ends up at
+// [y+depth][x+depth] of the returned views.
+func (s *scratch) haloBlock(world [][]byte, b block, depth, width, height int) (local, next [][]byte) {
+	rows := b.endY - b.startY + 2*depth
+	cols := b.endX - b.startX + 2*depth
+	for i := 0; i < rows; i++ {
+		s.localRows[i] = s.local[i][:cols]
+		s.nextRows[i] = s.next[i][:cols]
+		row := world[((b.startY+i-depth)%height+height)%height]
+		for j := range s.localRows[i] {
+			s.localRows[i][j] = row[((b.startX+j-depth)%width+width)%width]
+		}
+	}
+	return s.localRows[:rows], s.nextRows[:rows]
+}
+
+// tileBlocks splits the world into full width tiles of TileHeight rows.
+func tileBlocks(p Params) []block {
+	var blocks []block
+	for startY := 0; startY < p.ImageHeight; startY += p.TileHeight {
+		endY := startY + p.TileHeight
+		if endY > p.ImageHeight {
+			endY = p.ImageHeight
+		}
+		blocks = append(blocks, block{startY: startY, endY: endY, startX: 0, endX: p.ImageWidth})
+	}
+	return blocks
+}
+
+// smallestPart returns the height or width of the smallest strip, tile or block the world is split into,
+// whichever is less, or 0 if it is not split into any.
+func smallestPart(p Params) int {
+	var blocks []block
+	if p.BlockRows > 0 && p.BlockCols > 0 {
+		blocks = blockGrid(p)
+	} else if p.TileHeight > 0 {
+		blocks = tileBlocks(p)
+	} else if p.Threads > 0 {
+		blocks = stripBlocks(p)
+	}
+	smallest := 0
+	for i, b := range blocks {
+		size := b.endY - b.startY
+		if b.endX-b.startX < size {
+			size = b.endX - b.startX
+		}
+		if i == 0 || size < smallest {
+			smallest = size
+		}
+	}
+	return smallest
+}
+
+// stripBlocks splits the world into one full width strip per worker, skipping any that would be empty.
+func stripBlocks(p Params) []block {
+	var blocks []block
+	for i := 0; i < p.Threads; i++ {
+		b := block{
+			startY: i * p.ImageHeight / p.Threads,
+			endY:   (i + 1) * p.ImageHeight / p.Threads,
+			startX: 0,
+			endX:   p.ImageWidth,
+		}
+		if b.endY > b.startY {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// stepBlock computes the next generation of a halo block into next. Cells within ring of the edge
+// are already stale, so only cells further in are computed and the valid region shrinks by one cell
+// on every side each step.
+func stepBlock(local, next [][]byte, ring int) {
+	for y := ring + 1; y < len(local)-ring-1; y++ {
+		up, mid, down := local[y-1], local[y], local[y+1]
+		for x := ring + 1; x < len(mid)-ring-1; x++ {
+			sum := int(up[x-1]) + int(up[x]) + int(up[x+1]) +
+				int(mid[x-1]) + int(mid[x+1]) +
+				int(down[x-1]) + int(down[x]) + int(down[x+1])
+			next[y][x] = nextCell(mid[x] == 255, sum/255)
+		}
+	}
+}
+
+// calculateBlock computes one block through a halo as deep as the number of generations, and writes
+// the interior of each generation into the matching world. Blocks are disjoint so workers never write
+// to the same cell.
+func calculateBlock(p Params, world [][]byte, generations [][][]byte, b block, s *scratch) {
+	depth := len(generations)
+	local, next := s.haloBlock(world, b, depth, p.ImageWidth, p.ImageHeight)
+	for g, newWorld := range generations {
+		stepBlock(local, next, g)
+		for y := b.startY; y < b.endY; y++ {
+			copy(newWorld[y][b.startX:b.endX], next[y-b.startY+depth][depth:])
+		}
+		local, next = next, local
+	}
+}
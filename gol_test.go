@@ -11,7 +11,8 @@ import (
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
-// TestGol tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using 1-16 worker threads.
+// TestGol tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using 1-16 worker threads,
+// both as horizontal strips and as the most square 2D grid of blocks for each thread count.
 func TestGol(t *testing.T) {
 	tests := []gol.Params{
 		{ImageWidth: 16, ImageHeight: 16},
@@ -41,6 +42,22 @@ func TestGol(t *testing.T) {
 					}
 					assertEqualBoard(t, cells, expectedAlive, p)
 				})
+
+				blocks := p
+				blocks.BlockRows, blocks.BlockCols = gol.SquareGrid(threads)
+				testName = fmt.Sprintf("%s-blocks%dx%d", testName, blocks.BlockRows, blocks.BlockCols)
+				t.Run(testName, func(t *testing.T) {
+					events := make(chan gol.Event)
+					go gol.Run(blocks, events, nil)
+					var cells []util.Cell
+					for event := range events {
+						switch e := event.(type) {
+						case gol.FinalTurnComplete:
+							cells = e.Alive
+						}
+					}
+					assertEqualBoard(t, cells, expectedAlive, blocks)
+				})
 			}
 		}
 	}
@@ -127,3 +144,39 @@ func readAliveCells(path string, width, height int) []util.Cell {
 	}
 	return cells
 }
+
+// TestGolTiles tests that the tile scheduler produces the same boards as the static split for a range of tile heights.
+func TestGolTiles(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		p.Turns = 100
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		for _, tileHeight := range []int{1, 5, 16} {
+			for _, threads := range []int{1, 3, 8, 16} {
+				p.TileHeight = tileHeight
+				p.Threads = threads
+				testName := fmt.Sprintf("%dx%dx%d-%d-tile%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads, p.TileHeight)
+				t.Run(testName, func(t *testing.T) {
+					events := make(chan gol.Event)
+					go gol.Run(p, events, nil)
+					var cells []util.Cell
+					for event := range events {
+						switch e := event.(type) {
+						case gol.FinalTurnComplete:
+							cells = e.Alive
+						}
+					}
+					assertEqualBoard(t, cells, expectedAlive, p)
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestHalo tests that computing several generations per synchronisation still sends CellFlipped and
+// TurnComplete events for every turn, and still finishes on the correct board.
+func TestHalo(t *testing.T) {
+	base := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100, Threads: 8}
+	alive := readAliveCounts(base.ImageWidth, base.ImageHeight)
+	expectedAlive := readAliveCells(
+		"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", base.ImageWidth, base.ImageHeight, base.Turns),
+		base.ImageWidth,
+		base.ImageHeight,
+	)
+
+	strips := base
+	strips.HaloDepth = 3
+	blocks := base
+	blocks.HaloDepth = 4
+	blocks.BlockRows, blocks.BlockCols = gol.SquareGrid(blocks.Threads)
+	tiles := base
+	tiles.HaloDepth = 7
+	tiles.TileHeight = 16
+
+	for _, p := range []gol.Params{strips, blocks, tiles} {
+		testName := fmt.Sprintf("%dx%dx%d-%d-halo%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads, p.HaloDepth)
+		t.Run(testName, func(t *testing.T) {
+			board := make([][]bool, p.ImageHeight)
+			for i := range board {
+				board[i] = make([]bool, p.ImageWidth)
+			}
+			count := 0
+			turns := 0
+			var cells []util.Cell
+
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			for event := range events {
+				switch e := event.(type) {
+				case gol.CellFlipped:
+					board[e.Cell.Y][e.Cell.X] = !board[e.Cell.Y][e.Cell.X]
+					if board[e.Cell.Y][e.Cell.X] {
+						count++
+					} else {
+						count--
+					}
+				case gol.TurnComplete:
+					turns++
+					if e.CompletedTurns != turns {
+						t.Fatalf("Expected TurnComplete for turn %d, got turn %d.", turns, e.CompletedTurns)
+					}
+					if alive[turns] != count {
+						t.Fatalf("Incorrect number of alive cells after CellFlipped events on turn %d. Was %d, should be %d.", turns, count, alive[turns])
+					}
+				case gol.FinalTurnComplete:
+					cells = e.Alive
+				}
+			}
+			if turns != p.Turns {
+				t.Errorf("Expected %d TurnComplete events, got %d.", p.Turns, turns)
+			}
+			assertEqualBoard(t, cells, expectedAlive, p)
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// nextControlEvent returns the next event that is not part of the normal stream of turns,
+// failing the test if none arrives within 5 seconds.
+func nextControlEvent(t *testing.T, events <-chan gol.Event) gol.Event {
+	timer := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("events channel closed before the expected event was received")
+			}
+			switch event.(type) {
+			case gol.CellFlipped, gol.TurnComplete, gol.AliveCellsCount:
+				continue
+			}
+			return event
+		case <-timer:
+			t.Fatal("no control event received in 5 seconds")
+		}
+	}
+}
+
+// awaitTurnComplete waits for the next TurnComplete and checks that it is for the expected turn.
+// Only CellFlipped and AliveCellsCount events may arrive before it.
+func awaitTurnComplete(t *testing.T, events <-chan gol.Event, expected int) {
+	timer := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			switch e := event.(type) {
+			case gol.CellFlipped, gol.AliveCellsCount:
+			case gol.TurnComplete:
+				if e.CompletedTurns != expected {
+					t.Fatalf("Expected TurnComplete for turn %v, got turn %v", expected, e.CompletedTurns)
+				}
+				return
+			default:
+				t.Fatalf("Unexpected %T %v while waiting for TurnComplete", event, event)
+			}
+		case <-timer:
+			t.Fatal("no TurnComplete received in 5 seconds")
+		}
+	}
+}
+
+// assertStateChange checks that the event is a StateChange to the expected state and returns its turn.
+func assertStateChange(t *testing.T, event gol.Event, expected gol.State) int {
+	e, ok := event.(gol.StateChange)
+	if !ok || e.NewState != expected {
+		t.Fatalf("Expected StateChange to %v, got %T %v", expected, event, event)
+	}
+	return e.CompletedTurns
+}
+
+// assertImageOutput checks that the event is an ImageOutputComplete for the expected turn.
+func assertImageOutput(t *testing.T, event gol.Event, p gol.Params, turn int) {
+	e, ok := event.(gol.ImageOutputComplete)
+	if !ok {
+		t.Fatalf("Expected ImageOutputComplete, got %T %v", event, event)
+	}
+	expected := fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, turn)
+	if e.CompletedTurns != turn || e.Filename != expected {
+		t.Fatalf("Expected ImageOutputComplete for %v at turn %v, got %v at turn %v", expected, turn, e.Filename, e.CompletedTurns)
+	}
+}
+
+// assertFinal checks that the run ends with FinalTurnComplete at the given turn, followed by Quitting,
+// and that the saved image matches the final board.
+func assertFinal(t *testing.T, events <-chan gol.Event, p gol.Params, turn int) {
+	final, ok := nextControlEvent(t, events).(gol.FinalTurnComplete)
+	if !ok || final.CompletedTurns != turn {
+		t.Fatalf("Expected FinalTurnComplete at turn %v, got %v", turn, final)
+	}
+	if quitTurn := assertStateChange(t, nextControlEvent(t, events), gol.Quitting); quitTurn != turn {
+		t.Fatalf("Expected Quitting at turn %v, got turn %v", turn, quitTurn)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("events channel was not closed after Quitting")
+	}
+	cellsFromImage := readAliveCells(
+		"out/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turn),
+		p.ImageWidth,
+		p.ImageHeight,
+	)
+	assertEqualBoard(t, cellsFromImage, final.Alive, p)
+}
+
+// TestKeyboard drives the keyPresses channel and checks the events sent in response to p, s, q, k, n, b, + and -.
+func TestKeyboard(t *testing.T) {
+	p := gol.Params{Turns: 100000000, Threads: 8, ImageWidth: 512, ImageHeight: 512, History: 10}
+
+	t.Run("pause-save-resume-quit", func(t *testing.T) {
+		events := make(chan gol.Event)
+		keyPresses := make(chan rune, 10)
+		go gol.Run(p, events, keyPresses)
+
+		keyPresses <- 'p'
+		pausedTurn := assertStateChange(t, nextControlEvent(t, events), gol.Paused)
+
+		keyPresses <- 's'
+		assertImageOutput(t, nextControlEvent(t, events), p, pausedTurn)
+
+		keyPresses <- 'p'
+		if turn := assertStateChange(t, nextControlEvent(t, events), gol.Executing); turn != pausedTurn {
+			t.Fatalf("Expected to resume at turn %v, got turn %v", pausedTurn, turn)
+		}
+
+		keyPresses <- 's'
+		saved := nextControlEvent(t, events)
+		assertImageOutput(t, saved, p, saved.GetCompletedTurns())
+
+		keyPresses <- 'q'
+		output := nextControlEvent(t, events)
+		assertImageOutput(t, output, p, output.GetCompletedTurns())
+		assertFinal(t, events, p, output.GetCompletedTurns())
+	})
+
+	t.Run("step-and-speed", func(t *testing.T) {
+		events := make(chan gol.Event)
+		keyPresses := make(chan rune, 10)
+		go gol.Run(p, events, keyPresses)
+
+		keyPresses <- 'p'
+		pausedTurn := assertStateChange(t, nextControlEvent(t, events), gol.Paused)
+
+		for i := 1; i <= 3; i++ {
+			keyPresses <- 'n'
+			awaitTurnComplete(t, events, pausedTurn+i)
+		}
+
+		timer := time.After(200 * time.Millisecond)
+	paused:
+		for {
+			select {
+			case event := <-events:
+				if _, ok := event.(gol.AliveCellsCount); !ok {
+					t.Fatalf("Unexpected %T %v while paused", event, event)
+				}
+			case <-timer:
+				break paused
+			}
+		}
+
+		keyPresses <- '-'
+		if e, ok := nextControlEvent(t, events).(gol.SpeedChange); !ok || e.TurnsPerSecond != 1024 {
+			t.Fatalf("Expected SpeedChange to 1024 turns/s, got %v", e)
+		}
+		keyPresses <- '-'
+		if e, ok := nextControlEvent(t, events).(gol.SpeedChange); !ok || e.TurnsPerSecond != 512 {
+			t.Fatalf("Expected SpeedChange to 512 turns/s, got %v", e)
+		}
+		keyPresses <- '+'
+		keyPresses <- '+'
+		nextControlEvent(t, events)
+		if e, ok := nextControlEvent(t, events).(gol.SpeedChange); !ok || e.TurnsPerSecond != 0 {
+			t.Fatalf("Expected SpeedChange to unlimited, got %v", e)
+		}
+
+		keyPresses <- 'q'
+		output := nextControlEvent(t, events)
+		assertImageOutput(t, output, p, pausedTurn+3)
+		assertFinal(t, events, p, pausedTurn+3)
+	})
+
+	t.Run("step-back-and-fork", func(t *testing.T) {
+		events := make(chan gol.Event)
+		keyPresses := make(chan rune, 10)
+		go gol.Run(p, events, keyPresses)
+
+		keyPresses <- 'p'
+		pausedTurn := assertStateChange(t, nextControlEvent(t, events), gol.Paused)
+		keyPresses <- 's'
+		assertImageOutput(t, nextControlEvent(t, events), p, pausedTurn)
+		pausedAlive := readAliveCells(
+			"out/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, pausedTurn),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+
+		keyPresses <- 'n'
+		awaitTurnComplete(t, events, pausedTurn+1)
+		keyPresses <- 'n'
+		awaitTurnComplete(t, events, pausedTurn+2)
+		keyPresses <- 'b'
+		awaitTurnComplete(t, events, pausedTurn+1)
+		keyPresses <- 'b'
+		awaitTurnComplete(t, events, pausedTurn)
+
+		// Stepping forward again from the rewound state must carry on with the same turn numbers.
+		keyPresses <- 'n'
+		awaitTurnComplete(t, events, pausedTurn+1)
+		keyPresses <- 'b'
+		awaitTurnComplete(t, events, pausedTurn)
+
+		keyPresses <- 'q'
+		assertImageOutput(t, nextControlEvent(t, events), p, pausedTurn)
+		final, ok := nextControlEvent(t, events).(gol.FinalTurnComplete)
+		if !ok || final.CompletedTurns != pausedTurn {
+			t.Fatalf("Expected FinalTurnComplete at turn %v, got %v", pausedTurn, final)
+		}
+		assertEqualBoard(t, final.Alive, pausedAlive, p)
+	})
+
+	t.Run("edit-while-paused", func(t *testing.T) {
+		events := make(chan gol.Event)
+		keyPresses := make(chan rune, 10)
+		edits := make(chan util.Cell, 10)
+		go gol.RunWithEdits(p, events, keyPresses, edits)
+
+		keyPresses <- 'p'
+		pausedTurn := assertStateChange(t, nextControlEvent(t, events), gol.Paused)
+		keyPresses <- 's'
+		assertImageOutput(t, nextControlEvent(t, events), p, pausedTurn)
+		pausedAlive := readAliveCells(
+			"out/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, pausedTurn),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+
+		// Toggle a block of cells twice over, so the board ends up where it started.
+		var toggled []util.Cell
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				toggled = append(toggled, util.Cell{X: x, Y: y})
+			}
+		}
+		for round := 0; round < 2; round++ {
+			for _, cell := range toggled {
+				edits <- cell
+				event := <-events
+				if _, ok := event.(gol.AliveCellsCount); ok {
+					event = <-events
+				}
+				flipped, ok := event.(gol.CellFlipped)
+				if !ok || flipped.Cell != cell || flipped.CompletedTurns != pausedTurn {
+					t.Fatalf("Expected CellFlipped for %v at turn %v, got %v", cell, pausedTurn, flipped)
+				}
+				awaitTurnComplete(t, events, pausedTurn)
+			}
+		}
+
+		keyPresses <- 'q'
+		assertImageOutput(t, nextControlEvent(t, events), p, pausedTurn)
+		final, ok := nextControlEvent(t, events).(gol.FinalTurnComplete)
+		if !ok || final.CompletedTurns != pausedTurn {
+			t.Fatalf("Expected FinalTurnComplete at turn %v, got %v", pausedTurn, final)
+		}
+		assertEqualBoard(t, final.Alive, pausedAlive, p)
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		events := make(chan gol.Event)
+		keyPresses := make(chan rune, 10)
+		go gol.Run(p, events, keyPresses)
+
+		keyPresses <- 'k'
+		output := nextControlEvent(t, events)
+		assertImageOutput(t, output, p, output.GetCompletedTurns())
+		assertFinal(t, events, p, output.GetCompletedTurns())
+	})
+}
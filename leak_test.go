@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// assertNoLeaks fails the test if more goroutines are running than before it started, once those that
+// are on their way out have had a second to return.
+func assertNoLeaks(t *testing.T, before int) {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("%v goroutines leaked:\n%s", runtime.NumGoroutine()-before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRunContext checks that the io goroutine, the workers and the tickers all return when a run
+// completes, when it is cancelled, even if nobody is receiving its events any more, and when its image
+// cannot be read.
+func TestRunContext(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	t.Run("complete", func(t *testing.T) {
+		p := gol.Params{Turns: 10, Threads: 8, ImageWidth: 64, ImageHeight: 64}
+		for i := 0; i < 10; i++ {
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			for range events {
+			}
+		}
+	})
+
+	p := gol.Params{Turns: 100000000, Threads: 8, ImageWidth: 512, ImageHeight: 512}
+
+	t.Run("cancel-running", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		events := make(chan gol.Event)
+		result := make(chan error)
+		go func() {
+			result <- gol.RunContext(ctx, p, events, nil, nil)
+		}()
+		awaitTurnComplete(t, events, 1)
+		awaitTurnComplete(t, events, 2)
+		cancel()
+		select {
+		case err := <-result:
+			if err != context.Canceled {
+				t.Fatalf("Expected RunContext to return %v, got %v", context.Canceled, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("RunContext did not return within 5 seconds of being cancelled")
+		}
+		for range events {
+		}
+	})
+
+	t.Run("cancel-paused", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		events := make(chan gol.Event)
+		keyPresses := make(chan rune, 10)
+		result := make(chan error)
+		go func() {
+			result <- gol.RunContext(ctx, p, events, keyPresses, nil)
+		}()
+		keyPresses <- 'p'
+		assertStateChange(t, nextControlEvent(t, events), gol.Paused)
+		cancel()
+		select {
+		case <-result:
+		case <-time.After(5 * time.Second):
+			t.Fatal("RunContext did not return within 5 seconds of being cancelled while paused")
+		}
+		for range events {
+		}
+	})
+
+	t.Run("missing-image", func(t *testing.T) {
+		events := make(chan gol.Event)
+		err := gol.RunContext(context.Background(), gol.Params{Turns: 10, Threads: 2, ImageWidth: 17, ImageHeight: 17}, events, nil, nil)
+		if err == nil {
+			t.Fatal("Expected an error for a world without a 17x17 image")
+		}
+		if _, ok := <-events; ok {
+			t.Fatal("Expected events to be closed without any being sent")
+		}
+	})
+
+	assertNoLeaks(t, before)
+}
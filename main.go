@@ -3,10 +3,15 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"os"
 	"runtime"
+	"time"
 
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/tui"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
@@ -38,6 +43,159 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.IntVar(
+		&params.TileHeight,
+		"tile",
+		0,
+		"Specify the height in rows of the tiles shared out between workers. Defaults to 0 (one strip per worker).")
+
+	flag.IntVar(
+		&params.BlockRows,
+		"blockRows",
+		0,
+		"Specify the number of rows of blocks in a 2D decomposition. Needs -blockCols. Defaults to 0 (no blocks).")
+
+	flag.IntVar(
+		&params.BlockCols,
+		"blockCols",
+		0,
+		"Specify the number of columns of blocks in a 2D decomposition. Needs -blockRows. Defaults to 0 (no blocks).")
+
+	flag.IntVar(
+		&params.HaloDepth,
+		"halo",
+		1,
+		"Specify the number of generations workers compute between synchronisations. Defaults to 1.")
+
+	flag.IntVar(
+		&params.TurnsPerSecond,
+		"tps",
+		0,
+		"Specify the target number of turns per second. Defaults to 0 (unthrottled).")
+
+	flag.IntVar(
+		&params.History,
+		"history",
+		100,
+		"Specify the number of turns that can be stepped back through with 'b' while paused. Defaults to 100.")
+
+	flag.BoolVar(
+		&params.Metrics,
+		"metrics",
+		false,
+		"Prints a rolling summary of per-worker timings. Defaults to false.")
+
+	flag.BoolVar(
+		&params.BatchFlips,
+		"batch",
+		false,
+		"Sends the cells flipped by each turn as one event instead of one event per cell. Always on with -noVis.")
+
+	patternPath := flag.String(
+		"pattern",
+		"",
+		"Specify an RLE file to place at the cursor with a right click while paused, or in the middle with -world pattern.")
+
+	var opts sdl.Options
+	flag.StringVar(
+		&opts.Theme,
+		"theme",
+		"classic",
+		"Specify the colour theme of the window: classic, phosphor, amber or paper. Press 't' to cycle through them.")
+
+	flag.BoolVar(
+		&opts.Age,
+		"age",
+		false,
+		"Colours cells by how long they have been alive, with fading trails. Press 'a' to toggle. Defaults to false.")
+
+	flag.BoolVar(
+		&opts.HideHUD,
+		"noHud",
+		false,
+		"Hides the overlay with the turn, population, rate, state and rule. Press 'h' to toggle. Defaults to false.")
+
+	flag.BoolVar(
+		&opts.Graph,
+		"graph",
+		false,
+		"Shows a graph of the population over time. Press 'g' to toggle. Defaults to false.")
+
+	useTui := flag.Bool(
+		"tui",
+		false,
+		"Shows the world in the terminal instead of an SDL window, for machines without a display.")
+
+	recordPath := flag.String(
+		"record",
+		"",
+		"Specify a file to record the events of the run to as JSON lines, compressed if it ends in .gz.")
+
+	replayPath := flag.String(
+		"replay",
+		"",
+		"Specify a file recorded with -record to replay instead of running a simulation.")
+
+	speed := flag.Float64(
+		"speed",
+		1,
+		"Specify how fast to replay: 1 keeps the recorded timing, 2 is twice as fast and 0 is as fast as possible. "+
+			"Cannot be negative.")
+
+	outputFormat := flag.String(
+		"output",
+		"text",
+		"Specify how -noVis prints events and the final summary: text, json (JSON lines) or csv. "+
+			"With json and csv everything else is printed to stderr, so that the output can be piped.")
+
+	flag.StringVar(
+		&params.ImageDir,
+		"images",
+		"images",
+		"Specify the directory the input image is read from.")
+
+	flag.StringVar(
+		&params.OutDir,
+		"out",
+		"out",
+		"Specify the directory images and population series are saved to.")
+
+	flag.StringVar(
+		&params.Generator,
+		"world",
+		"",
+		"Generates the initial world instead of reading an image: random, c2, c4 or d8 for a soup with that "+
+			"symmetry, noise for a repeated random tile, or pattern for the -pattern file in the middle.")
+
+	flag.Int64Var(
+		&params.Seed,
+		"seed",
+		0,
+		"Specify the seed of -world, which makes the same world every time. Defaults to a new seed, which is printed.")
+
+	flag.Float64Var(
+		&params.Density,
+		"density",
+		0.5,
+		"Specify the chance that each cell of a -world soup or noise is alive. Defaults to 0.5.")
+
+	flag.IntVar(
+		&params.SoupSize,
+		"soup",
+		0,
+		"Specify the side of the -world soup placed in the middle of an empty world, or of the noise tile. "+
+			"Defaults to filling the world, and 16 for noise.")
+
+	configPath := flag.String(
+		"config",
+		"",
+		"Specify a JSON config file setting any of these flags by name. Flags given on the command line win over it.")
+
+	preset := flag.String(
+		"preset",
+		"",
+		"Specify a preset of the config file to apply on top of the rest of it.")
+
 	noVis := flag.Bool(
 		"noVis",
 		false,
@@ -45,24 +203,146 @@ func main() {
 
 	flag.Parse()
 
-	fmt.Println("Threads:", params.Threads)
-	fmt.Println("Width:", params.ImageWidth)
-	fmt.Println("Height:", params.ImageHeight)
+	var settings config
+	if *configPath != "" {
+		var err error
+		settings, err = loadConfig(*configPath, *preset, flag.CommandLine)
+		exitIfInvalid(err)
+		opts.Keys = settings.bindings
+	} else if *preset != "" {
+		exitIfInvalid(fmt.Errorf("-preset %v needs a -config file", *preset))
+	}
+
+	if params.Generator == "pattern" {
+		if *patternPath == "" {
+			exitIfInvalid(fmt.Errorf("-world pattern needs a -pattern file"))
+		}
+		params.PatternFile = *patternPath
+	}
+	if *patternPath != "" {
+		pattern, err := util.ReadRLE(*patternPath)
+		exitIfInvalid(err)
+		if params.Generator == "pattern" {
+			// The world is only made once the run has started, so a pattern that does not fit is caught here.
+			_, err := pattern.Centred(params.ImageWidth, params.ImageHeight)
+			exitIfInvalid(err)
+		}
+		opts.Pattern = pattern
+	}
+	seeded := false
+	flag.Visit(func(f *flag.Flag) {
+		seeded = seeded || f.Name == "seed"
+	})
+	if params.Generator != "" && !seeded {
+		params.Seed = time.Now().UnixNano()
+	}
+
+	output, err := newReport(*outputFormat, os.Stdout)
+	exitIfInvalid(err)
+	// Everything but the report is a diagnostic, which goes to stderr with json and csv so that the
+	// report can be piped on its own.
+	var diag io.Writer = os.Stdout
+	if *outputFormat != "text" {
+		diag = os.Stderr
+	}
+	gol.SetOutput(diag)
+
+	if *speed < 0 {
+		exitIfInvalid(fmt.Errorf("-speed cannot be negative, not %v", *speed))
+	}
+	var replay *gol.EventReader
+	if *replayPath != "" {
+		var err error
+		replay, err = gol.OpenEventLog(*replayPath)
+		exitIfInvalid(err)
+		defer replay.Close()
+		params = replay.Params
+	}
+	exitIfInvalid(checkSettings(params, replay == nil && params.Generator == ""))
+	params, warning := params.Clamp()
+	if warning != "" {
+		fmt.Fprintln(diag, warning)
+	}
+
+	fmt.Fprintln(diag, "Threads:", params.Threads)
+	fmt.Fprintln(diag, "Width:", params.ImageWidth)
+	fmt.Fprintln(diag, "Height:", params.ImageHeight)
+	if generated := params.Generated(); generated != "" {
+		fmt.Fprintln(diag, "World:", generated)
+	}
+
+	_, err = sdl.FindTheme(opts.Theme)
+	exitIfInvalid(err)
+
+	if *noVis && !*useTui {
+		// Nothing looks at the flipped cells without a view, so they are sent as cheaply as possible.
+		params.BatchFlips = true
+	}
 
 	keyPresses := make(chan rune, 10)
-	events := make(chan gol.Event, 1000)
+	edits := make(chan util.Cell, 1000)
+	start := time.Now()
+	var events <-chan gol.Event
+	var broadcaster *gol.Broadcaster
+	var recorded chan error
+	if replay != nil {
+		replayed := make(chan gol.Event, 1000)
+		events = replayed
+		go func() {
+			if err := gol.Replay(replay, replayed, keyPresses, *speed); err != nil {
+				fmt.Fprintln(diag, "Replay stopped early:", err)
+			}
+		}()
+	} else if *recordPath != "" {
+		recordLog, err := gol.CreateEventLog(*recordPath, params)
+		exitIfInvalid(err)
+		broadcaster = gol.NewBroadcaster()
+		events = broadcaster.Subscribe(1000, gol.Block)
+		recording := broadcaster.Subscribe(1000, gol.Block)
+		recorded = make(chan error, 1)
+		go func() {
+			recorded <- gol.RecordEvents(recordLog, recording)
+		}()
+		go gol.RunWithEdits(params, broadcaster.Events(), keyPresses, edits)
+	} else {
+		run := make(chan gol.Event, 1000)
+		events = run
+		go gol.RunWithEdits(params, run, keyPresses, edits)
+	}
 
-	go gol.Run(params, events, keyPresses)
-	if !(*noVis) {
-		sdl.Run(params, events, keyPresses)
+	if *useTui {
+		tui.Run(params, events, keyPresses, settings.bindings)
+	} else if !(*noVis) {
+		sdl.Run(params, events, keyPresses, edits, opts)
 	} else {
-		complete := false
-		for !complete {
-			event := <-events
-			switch event.(type) {
+		var summary gol.MetricsSummary
+		var final *gol.FinalTurnComplete
+		var elapsed time.Duration
+		for event := range events {
+			switch e := event.(type) {
+			case gol.WorkerMetrics:
+				if summary.Add(e) {
+					fmt.Fprint(diag, summary.Flush())
+				}
 			case gol.FinalTurnComplete:
-				complete = true
+				fmt.Fprint(diag, summary.Flush())
+				final, elapsed = &e, time.Since(start)
+			default:
+				output.event(event)
 			}
 		}
+		if final != nil {
+			output.summary(final.CompletedTurns, len(final.Alive), elapsed)
+		}
+	}
+
+	if recorded != nil {
+		// The view returns at FinalTurnComplete, before the last events, so it must not hold back the recording.
+		broadcaster.Unsubscribe(events)
+		if err := <-recorded; err != nil {
+			fmt.Fprintln(diag, "Could not record the events:", err)
+		} else {
+			fmt.Fprintln(diag, "Events recorded to", *recordPath)
+		}
 	}
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestMetrics checks that a WorkerMetrics event with a timing for every worker is sent just before the
+// TurnComplete of each turn, that the work of the workers covers the whole world for strips, tiles and
+// blocks, and that no WorkerMetrics are sent unless Metrics is set.
+func TestMetrics(t *testing.T) {
+	base := gol.Params{Turns: 20, Threads: 4, ImageWidth: 64, ImageHeight: 64, Metrics: true, BatchFlips: true}
+	strips := base
+	tiles := base
+	tiles.TileHeight = 5
+	blocks := base
+	blocks.BlockRows, blocks.BlockCols = 2, 3
+	tests := []struct {
+		name string
+		p    gol.Params
+		rows int
+	}{
+		{"strips", strips, base.ImageHeight},
+		{"tiles", tiles, base.ImageHeight},
+		{"blocks", blocks, base.ImageHeight * blocks.BlockCols},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.p
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			var previous gol.Event
+			metrics := 0
+			for event := range events {
+				switch e := event.(type) {
+				case gol.WorkerMetrics:
+					metrics++
+					if len(e.Workers) != p.Threads {
+						t.Fatalf("Expected a timing for each of the %v workers, got %v", p.Threads, len(e.Workers))
+					}
+					rows, cells := 0, 0
+					for _, w := range e.Workers {
+						rows += w.Rows
+						cells += w.Cells
+					}
+					if rows != test.rows || cells != p.ImageWidth*p.ImageHeight {
+						t.Fatalf("Turn %v: expected %v rows and %v cells, got %v and %v",
+							e.CompletedTurns, test.rows, p.ImageWidth*p.ImageHeight, rows, cells)
+					}
+				case gol.TurnComplete:
+					m, ok := previous.(gol.WorkerMetrics)
+					if !ok || m.CompletedTurns != e.CompletedTurns {
+						t.Fatalf("Expected WorkerMetrics for turn %v just before its TurnComplete, got %v",
+							e.CompletedTurns, fmt.Sprintf("%T", previous))
+					}
+				}
+				previous = event
+			}
+			if metrics != p.Turns {
+				t.Errorf("Expected %v WorkerMetrics events, got %v", p.Turns, metrics)
+			}
+		})
+	}
+
+	t.Run("halo", func(t *testing.T) {
+		p := base
+		p.HaloDepth = 3
+		events := make(chan gol.Event)
+		go gol.Run(p, events, nil)
+		var summary gol.MetricsSummary
+		var previous gol.Event
+		metrics, turns := 0, 0
+		for event := range events {
+			switch e := event.(type) {
+			case gol.WorkerMetrics:
+				metrics++
+				turns += e.Turns
+				if e.Turns < 1 || e.Turns > p.HaloDepth {
+					t.Fatalf("Turn %v: expected metrics covering 1 to %v turns, got %v", e.CompletedTurns, p.HaloDepth, e.Turns)
+				}
+				summary.Add(e)
+			case gol.TurnComplete:
+				if e.CompletedTurns%p.HaloDepth == 0 || e.CompletedTurns == p.Turns {
+					if m, ok := previous.(gol.WorkerMetrics); !ok || m.CompletedTurns != e.CompletedTurns {
+						t.Fatalf("Expected WorkerMetrics for turn %v just before its TurnComplete, got %v",
+							e.CompletedTurns, fmt.Sprintf("%T", previous))
+					}
+				}
+			}
+			previous = event
+		}
+		epochs := (p.Turns + p.HaloDepth - 1) / p.HaloDepth
+		if metrics != epochs || turns != p.Turns {
+			t.Errorf("Expected %v WorkerMetrics events covering %v turns, got %v covering %v", epochs, p.Turns, metrics, turns)
+		}
+		want := fmt.Sprintf("Turns 1-%v: %v turns", p.Turns, p.Turns)
+		if got := summary.Flush(); !strings.HasPrefix(got, want) {
+			t.Errorf("Expected the summary to start with %q, got %q", want, got)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		p := base
+		p.Metrics = false
+		events := make(chan gol.Event)
+		go gol.Run(p, events, nil)
+		for event := range events {
+			if _, ok := event.(gol.WorkerMetrics); ok {
+				t.Fatalf("Expected no WorkerMetrics without Metrics, got one for turn %v", event.GetCompletedTurns())
+			}
+		}
+	})
+}
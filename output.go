@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// outputFormats are the formats of -output.
+var outputFormats = []string{"text", "json", "csv"}
+
+// report prints the events of a headless run that scripts care about, AliveCellsCount, StateChange and
+// ImageOutputComplete, followed by a summary of the whole run. Text is meant for people, while JSON
+// lines and CSV with a header row are meant to be piped into other tools.
+type report struct {
+	format string
+	out    io.Writer
+	csv    *csv.Writer
+}
+
+// reportJSON is a line of the JSON format. Only the fields of the type of line are filled in.
+type reportJSON struct {
+	Type           string   `json:"type"`
+	CompletedTurns int      `json:"completedTurns"`
+	AliveCells     *int     `json:"aliveCells,omitempty"`
+	State          string   `json:"state,omitempty"`
+	Filename       string   `json:"filename,omitempty"`
+	ElapsedSeconds *float64 `json:"elapsedSeconds,omitempty"`
+	TurnsPerSecond *float64 `json:"turnsPerSecond,omitempty"`
+}
+
+var reportCSVHeader = []string{"type", "completed_turns", "alive_cells", "state", "filename", "elapsed_seconds", "turns_per_second"}
+
+// newReport returns a report printing to out in format, which must be one of outputFormats.
+func newReport(format string, out io.Writer) (*report, error) {
+	for _, known := range outputFormats {
+		if format == known {
+			return &report{format: format, out: out}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown output format %q, expected one of %v", format, outputFormats)
+}
+
+// event prints an event if it is one of those reported.
+func (r *report) event(event gol.Event) {
+	line := reportJSON{Type: strings.TrimPrefix(fmt.Sprintf("%T", event), "gol."), CompletedTurns: event.GetCompletedTurns()}
+	switch e := event.(type) {
+	case gol.AliveCellsCount:
+		line.AliveCells = &e.CellsCount
+	case gol.StateChange:
+		line.State = e.NewState.String()
+	case gol.ImageOutputComplete:
+		line.Filename = e.Filename
+	default:
+		return
+	}
+	if r.format == "text" {
+		fmt.Fprintf(r.out, "Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
+		return
+	}
+	r.write(line)
+}
+
+// summary prints the final turn and number of alive cells, how long the run took and how fast it went.
+func (r *report) summary(turns, alive int, elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	rate := 0.0
+	if seconds > 0 {
+		rate = float64(turns) / seconds
+	}
+	if r.format == "text" {
+		fmt.Fprintf(r.out, "Completed Turns %-8vAlive Cells %v in %.3fs, %.1f turns/s\n", turns, alive, seconds, rate)
+		return
+	}
+	r.write(reportJSON{
+		Type:           "Summary",
+		CompletedTurns: turns,
+		AliveCells:     &alive,
+		ElapsedSeconds: &seconds,
+		TurnsPerSecond: &rate,
+	})
+}
+
+// write prints a line in the JSON or CSV format. Each line is flushed straight away, so that a pipe
+// sees the events as they happen.
+func (r *report) write(line reportJSON) {
+	if r.format == "json" {
+		_ = json.NewEncoder(r.out).Encode(line)
+		return
+	}
+	if r.csv == nil {
+		r.csv = csv.NewWriter(r.out)
+		r.csv.Write(reportCSVHeader)
+	}
+	record := []string{line.Type, strconv.Itoa(line.CompletedTurns), "", line.State, line.Filename, "", ""}
+	if line.AliveCells != nil {
+		record[2] = strconv.Itoa(*line.AliveCells)
+	}
+	if line.ElapsedSeconds != nil {
+		record[5] = strconv.FormatFloat(*line.ElapsedSeconds, 'f', 3, 64)
+		record[6] = strconv.FormatFloat(*line.TurnsPerSecond, 'f', 1, 64)
+	}
+	r.csv.Write(record)
+	r.csv.Flush()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestReport checks each format of -output, and that events other than AliveCellsCount, StateChange and
+// ImageOutputComplete are left out.
+func TestReport(t *testing.T) {
+	events := []gol.Event{
+		gol.CellFlipped{CompletedTurns: 1, Cell: util.Cell{X: 1, Y: 2}},
+		gol.TurnComplete{CompletedTurns: 1},
+		gol.AliveCellsCount{CompletedTurns: 40, CellsCount: 0},
+		gol.StateChange{CompletedTurns: 50, NewState: gol.Paused},
+		gol.ImageOutputComplete{CompletedTurns: 50, Filename: "16x16x50"},
+	}
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"text", "" +
+			"Completed Turns 40      Alive Cells 0\n" +
+			"Completed Turns 50      Paused\n" +
+			"Completed Turns 50      File 16x16x50 output complete\n" +
+			"Completed Turns 100     Alive Cells 5 in 2.000s, 50.0 turns/s\n"},
+		{"json", "" +
+			`{"type":"AliveCellsCount","completedTurns":40,"aliveCells":0}` + "\n" +
+			`{"type":"StateChange","completedTurns":50,"state":"Paused"}` + "\n" +
+			`{"type":"ImageOutputComplete","completedTurns":50,"filename":"16x16x50"}` + "\n" +
+			`{"type":"Summary","completedTurns":100,"aliveCells":5,"elapsedSeconds":2,"turnsPerSecond":50}` + "\n"},
+		{"csv", "" +
+			"type,completed_turns,alive_cells,state,filename,elapsed_seconds,turns_per_second\n" +
+			"AliveCellsCount,40,0,,,,\n" +
+			"StateChange,50,,Paused,,,\n" +
+			"ImageOutputComplete,50,,,16x16x50,,\n" +
+			"Summary,100,5,,,2.000,50.0\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			r, err := newReport(test.format, out)
+			util.Check(err)
+			for _, event := range events {
+				r.event(event)
+			}
+			r.summary(100, 5, 2*time.Second)
+			if out.String() != test.expected {
+				t.Errorf("Expected:\n%v\nGot:\n%v", test.expected, out.String())
+			}
+		})
+	}
+
+	if _, err := newReport("xml", &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
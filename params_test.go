@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestValidate checks that params the Game of Life cannot run with are rejected with the reason.
+func TestValidate(t *testing.T) {
+	valid := gol.Params{Turns: 10, Threads: 4, ImageWidth: 16, ImageHeight: 16}
+	tests := []struct {
+		name   string
+		change func(p *gol.Params)
+		err    string
+	}{
+		{"valid", func(p *gol.Params) {}, ""},
+		{"no turns", func(p *gol.Params) { p.Turns = 0 }, ""},
+		{"more threads than rows", func(p *gol.Params) { p.Threads = 64 }, ""},
+		{"no threads", func(p *gol.Params) { p.Threads = 0 }, "at least one thread, not 0"},
+		{"negative threads", func(p *gol.Params) { p.Threads = -2 }, "at least one thread, not -2"},
+		{"no width", func(p *gol.Params) { p.ImageWidth = 0 }, "positive size, not 0x16"},
+		{"negative height", func(p *gol.Params) { p.ImageHeight = -16 }, "positive size, not 16x-16"},
+		{"negative turns", func(p *gol.Params) { p.Turns = -1 }, "turns cannot be negative"},
+		{"negative tile", func(p *gol.Params) { p.TileHeight = -1 }, "cannot be negative"},
+		{"negative halo", func(p *gol.Params) { p.HaloDepth = -1 }, "cannot be negative"},
+		{"deep halo", func(p *gol.Params) { p.HaloDepth = 100 }, ""},
+		{"block rows only", func(p *gol.Params) { p.BlockRows = 2 }, "must be set together, not 2 and 0"},
+		{"block cols only", func(p *gol.Params) { p.BlockCols = 2 }, "must be set together, not 0 and 2"},
+		{"blocks taller than the world", func(p *gol.Params) { p.BlockRows, p.BlockCols = 17, 2 }, "2x17 grid of blocks does not fit"},
+		{"blocks wider than the world", func(p *gol.Params) { p.BlockRows, p.BlockCols = 2, 17 }, "17x2 grid of blocks does not fit"},
+		{"negative speed", func(p *gol.Params) { p.TurnsPerSecond = -1 }, "speed cannot be negative"},
+		{"fastest speed", func(p *gol.Params) { p.TurnsPerSecond = 1024 }, ""},
+		{"too fast", func(p *gol.Params) { p.TurnsPerSecond = 2000000000 }, "more than 1024 turns/s, not 2000000000"},
+		{"negative history", func(p *gol.Params) { p.History = -1 }, "history cannot be negative"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := valid
+			test.change(&p)
+			err := p.Validate()
+			if test.err == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+// TestClamp checks that threads are clamped to the number of parts the world is split into, and the
+// halo depth to the size of the smallest of them.
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        gol.Params
+		expected int
+		halo     int
+	}{
+		{"strips", gol.Params{Threads: 8, ImageWidth: 16, ImageHeight: 16}, 8, 0},
+		{"one per row", gol.Params{Threads: 16, ImageWidth: 16, ImageHeight: 16}, 16, 0},
+		{"more than rows", gol.Params{Threads: 64, ImageWidth: 16, ImageHeight: 16}, 16, 0},
+		{"tiles", gol.Params{Threads: 8, ImageWidth: 16, ImageHeight: 16, TileHeight: 5}, 4, 0},
+		{"blocks", gol.Params{Threads: 64, ImageWidth: 16, ImageHeight: 16, BlockRows: 4, BlockCols: 8}, 32, 0},
+		{"narrow blocks", gol.Params{Threads: 64, ImageWidth: 8, ImageHeight: 16, BlockRows: 2, BlockCols: 8}, 16, 0},
+		{"halo within strips", gol.Params{Threads: 4, ImageWidth: 16, ImageHeight: 16, HaloDepth: 4}, 4, 4},
+		{"halo deeper than strips", gol.Params{Threads: 4, ImageWidth: 16, ImageHeight: 16, HaloDepth: 5}, 4, 4},
+		{"halo deeper than the last tile", gol.Params{Threads: 2, ImageWidth: 16, ImageHeight: 16, TileHeight: 6, HaloDepth: 8}, 2, 4},
+		{"halo wider than blocks", gol.Params{Threads: 2, ImageWidth: 8, ImageHeight: 16, BlockRows: 2, BlockCols: 4, HaloDepth: 3}, 2, 2},
+		{"threads and halo", gol.Params{Threads: 64, ImageWidth: 16, ImageHeight: 16, HaloDepth: 2}, 16, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, warning := test.p.Clamp()
+			if p.Threads != test.expected {
+				t.Errorf("Expected %v threads, got %v", test.expected, p.Threads)
+			}
+			if p.HaloDepth != test.halo {
+				t.Errorf("Expected a halo depth of %v, got %v", test.halo, p.HaloDepth)
+			}
+			clamped := test.p.Threads != test.expected || test.p.HaloDepth != test.halo
+			if (warning != "") != clamped {
+				t.Errorf("Expected a warning only if the threads or halo depth were clamped, got %q", warning)
+			}
+		})
+	}
+}
+
+// TestRunInvalid checks that Run closes events straight away instead of starting with invalid params,
+// and that a run with more threads than rows still gives the right answer.
+func TestRunInvalid(t *testing.T) {
+	events := make(chan gol.Event)
+	go gol.Run(gol.Params{Turns: 1, Threads: 0, ImageWidth: 16, ImageHeight: 16}, events, nil)
+	select {
+	case event, ok := <-events:
+		if ok {
+			t.Errorf("Expected no events, got %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected events to be closed within a second")
+	}
+
+	p := gol.Params{Turns: 100, Threads: 32, ImageWidth: 16, ImageHeight: 16}
+	events = make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	expected := readAliveCells("check/images/16x16x100.pgm", p.ImageWidth, p.ImageHeight)
+	for event := range events {
+		if final, ok := event.(gol.FinalTurnComplete); ok {
+			assertEqualBoard(t, final.Alive, expected, p)
+		}
+	}
+}
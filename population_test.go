@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestPopulation records the population of a 64x64 run from its events and checks that the saved CSV
+// matches check/alive, header included.
+func TestPopulation(t *testing.T) {
+	p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 64, ImageHeight: 64}
+	expected := readAliveCounts(p.ImageWidth, p.ImageHeight)
+
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var population gol.Population
+	board := make(map[util.Cell]bool)
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			if board[e.Cell] {
+				delete(board, e.Cell)
+			} else {
+				board[e.Cell] = true
+			}
+		case gol.TurnComplete:
+			population.Record(e.CompletedTurns, len(board))
+		}
+	}
+
+	path, err := population.Save(p)
+	util.Check(err)
+	if path != "out/64x64x100.csv" {
+		t.Fatalf("Expected the series to be saved to out/64x64x100.csv, got %v", path)
+	}
+	f, err := os.Open(path)
+	util.Check(err)
+	defer f.Close()
+	table, err := csv.NewReader(f).ReadAll()
+	util.Check(err)
+
+	if len(table) != p.Turns+1 || table[0][0] != "completed_turns" || table[0][1] != "alive_cells" {
+		t.Fatalf("Expected a header and %v rows, got %v rows starting with %v", p.Turns, len(table)-1, table[0])
+	}
+	for _, row := range table[1:] {
+		turn, _ := strconv.Atoi(row[0])
+		count, _ := strconv.Atoi(row[1])
+		if expected[turn] != count {
+			t.Fatalf("At turn %v expected %v alive cells, got %v", turn, expected[turn], count)
+		}
+	}
+
+	// Recording an earlier turn again, as after stepping back, forgets the turns after it.
+	population.Record(10, 7)
+	if counts := population.Counts(); len(counts) != 10 || counts[9] != 7 {
+		t.Fatalf("Expected 10 turns ending with 7 alive cells, got %v turns ending with %v", len(counts), counts[len(counts)-1])
+	}
+}
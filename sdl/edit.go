@@ -0,0 +1,87 @@
+package sdl
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// editor turns mouse input on a paused window into cell toggles for the engine.
+// The window is only changed once the engine echoes each toggle back as a CellFlipped event,
+// so the display never gets ahead of the engine state.
+type editor struct {
+	edits   chan<- util.Cell
+	pattern util.Pattern
+	paused  bool
+	// touched holds the cells already toggled by the current drag, so each is toggled once.
+	touched map[util.Cell]bool
+	// pending holds toggles not yet taken by the engine. They are sent without blocking so that the
+	// window keeps draining events while a large pattern is placed.
+	pending []util.Cell
+}
+
+// flush sends as many pending toggles as the engine will take right now.
+func (ed *editor) flush() {
+	for len(ed.pending) > 0 {
+		select {
+		case ed.edits <- ed.pending[0]:
+			ed.pending = ed.pending[1:]
+		default:
+			return
+		}
+	}
+}
+
+// toggle asks the engine to flip a cell, unless the current drag has already flipped it.
+func (ed *editor) toggle(cell util.Cell) {
+	if ed.touched[cell] {
+		return
+	}
+	ed.touched[cell] = true
+	ed.pending = append(ed.pending, cell)
+}
+
+// place brings every cell of the pattern to life with its top left corner at the given cell,
+// wrapping around the edges of the world. A cell is judged by the state it will have once the pending
+// toggles are applied, so a pattern larger than the world, or placed twice before the engine has caught
+// up, never toggles a cell back to dead.
+func (ed *editor) place(w *Window, at util.Cell) {
+	flipped := make(map[util.Cell]bool, len(ed.pending))
+	for _, cell := range ed.pending {
+		flipped[cell] = !flipped[cell]
+	}
+	for _, c := range ed.pattern.Alive {
+		cell := util.Cell{X: (at.X + c.X) % int(w.Width), Y: (at.Y + c.Y) % int(w.Height)}
+		if w.IsSet(cell.X, cell.Y) == flipped[cell] {
+			ed.pending = append(ed.pending, cell)
+			flipped[cell] = !flipped[cell]
+		}
+	}
+}
+
+// handle acts on a mouse event: a left click or drag toggles cells and a right click places the pattern.
+func (ed *editor) handle(w *Window, event sdl.Event) {
+	if ed.edits == nil || !ed.paused {
+		return
+	}
+	switch e := event.(type) {
+	case *sdl.MouseButtonEvent:
+		cell, ok := w.CellAt(e.X, e.Y)
+		if !ok || e.Type != sdl.MOUSEBUTTONDOWN {
+			return
+		}
+		switch e.Button {
+		case sdl.BUTTON_LEFT:
+			ed.touched = make(map[util.Cell]bool)
+			ed.toggle(cell)
+		case sdl.BUTTON_RIGHT:
+			ed.place(w, cell)
+		}
+	case *sdl.MouseMotionEvent:
+		if e.State&sdl.ButtonLMask() == 0 || ed.touched == nil {
+			return
+		}
+		if cell, ok := w.CellAt(e.X, e.Y); ok {
+			ed.toggle(cell)
+		}
+	}
+}
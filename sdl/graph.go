@@ -0,0 +1,138 @@
+package sdl
+
+import (
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// maxBuckets is the most columns the population graph is drawn with. Once a series has more turns
+// than that, neighbouring buckets are merged so that drawing the graph costs the same however long
+// the run has been going.
+const maxBuckets = 512
+
+// graph plots a population series as the range of alive cells over each bucket of turns.
+type graph struct {
+	visible bool
+	// bucket is the number of turns in each of lo and hi, and covered the number of turns added.
+	bucket  int
+	covered int
+	// last is the count added for the last covered turn, so that a turn recorded again is noticed.
+	last   int32
+	lo, hi []int32
+	peak   int32
+	rects  []sdl.Rect
+}
+
+// update adds the turns of counts that the graph has not seen yet. The graph is rebuilt if counts
+// has become shorter, as it does after stepping back, or if the last turn it covers has a new count, as
+// it does after editing cells while paused, so that no bucket or the peak keeps a count that is gone.
+func (g *graph) update(counts []int32) {
+	changed := g.covered > 0 && len(counts) >= g.covered && counts[g.covered-1] != g.last
+	if len(counts) < g.covered || changed || g.bucket == 0 {
+		g.bucket, g.covered, g.peak = 1, 0, 0
+		g.lo, g.hi = g.lo[:0], g.hi[:0]
+	}
+	for ; g.covered < len(counts); g.covered++ {
+		alive := counts[g.covered]
+		if alive > g.peak {
+			g.peak = alive
+		}
+		i := g.covered / g.bucket
+		if i == len(g.lo) {
+			if i == maxBuckets {
+				g.merge()
+				i = g.covered / g.bucket
+			}
+			if i == len(g.lo) {
+				g.lo = append(g.lo, alive)
+				g.hi = append(g.hi, alive)
+				continue
+			}
+		}
+		if alive < g.lo[i] {
+			g.lo[i] = alive
+		}
+		if alive > g.hi[i] {
+			g.hi[i] = alive
+		}
+	}
+	if g.covered > 0 {
+		g.last = counts[g.covered-1]
+	}
+}
+
+// merge halves the number of buckets by joining each pair of them.
+func (g *graph) merge() {
+	n := len(g.lo) / 2
+	for i := 0; i < n; i++ {
+		g.lo[i] = min32(g.lo[2*i], g.lo[2*i+1])
+		g.hi[i] = max32(g.hi[2*i], g.hi[2*i+1])
+	}
+	if len(g.lo)%2 == 1 {
+		g.lo[n], g.hi[n] = g.lo[2*n], g.hi[2*n]
+		n++
+	}
+	g.lo, g.hi = g.lo[:n], g.hi[:n]
+	g.bucket *= 2
+}
+
+// drawGraph draws the population graph on a translucent panel along the bottom of the window.
+func (w *Window) drawGraph(g *graph) {
+	width, height := w.window.GetSize()
+	panel := sdl.Rect{X: 0, Y: height - height/4, W: width, H: height / 4}
+	err := w.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	util.Check(err)
+	err = w.renderer.SetDrawColor(0, 0, 0, 0xB0)
+	util.Check(err)
+	err = w.renderer.FillRect(&panel)
+	util.Check(err)
+	if len(g.lo) == 0 || g.peak == 0 {
+		return
+	}
+
+	plot := sdl.Rect{
+		X: panel.X + hudMargin,
+		Y: panel.Y + 2*hudMargin + glyphHeight*hudScale,
+		W: panel.W - 2*hudMargin,
+		H: panel.H - 3*hudMargin - glyphHeight*hudScale,
+	}
+	n := int32(len(g.lo))
+	columnWidth := plot.W / n
+	if columnWidth < 1 {
+		columnWidth = 1
+	}
+	toY := func(alive int32) int32 {
+		return plot.Y + plot.H - int32(int64(alive)*int64(plot.H)/int64(g.peak))
+	}
+	g.rects = g.rects[:0]
+	for i := int32(0); i < n; i++ {
+		top, bottom := toY(g.hi[i]), toY(g.lo[i])
+		g.rects = append(g.rects, sdl.Rect{X: plot.X + i*plot.W/n, Y: top, W: columnWidth, H: bottom - top + 1})
+	}
+	err = w.renderer.SetDrawColor(0x40, 0xC0, 0xFF, 0xFF)
+	util.Check(err)
+	err = w.renderer.FillRects(g.rects)
+	util.Check(err)
+
+	g.rects = textRects(g.rects[:0], fmt.Sprintf("Peak %v  Turns %v", g.peak, g.covered), plot.X, panel.Y+hudMargin)
+	err = w.renderer.SetDrawColor(0xFF, 0xFF, 0xFF, 0xFF)
+	util.Check(err)
+	err = w.renderer.FillRects(g.rects)
+	util.Check(err)
+}
+
+func min32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
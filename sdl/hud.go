@@ -0,0 +1,172 @@
+package sdl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+	// hudScale is the size in pixels of each dot of the font.
+	hudScale = 2
+	// hudMargin is the space in pixels around the text of the overlay.
+	hudMargin = 6
+	// rateInterval is how often the turns per second are worked out.
+	rateInterval = 500 * time.Millisecond
+)
+
+// font is a 5x7 bitmap font covering what the overlay needs to show. Each row of a glyph is a bitmask
+// with the leftmost dot in bit 4.
+var font = map[rune][glyphHeight]uint8{
+	' ': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'A': {0x0E, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'%': {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+}
+
+// hud keeps track of what the overlay shows: the completed turns, the rate at which turns are being
+// completed, and whether the run is paused. The number of alive cells comes from the window.
+type hud struct {
+	visible  bool
+	turn     int
+	paused   bool
+	rate     float64
+	rateTurn int
+	rateTime time.Time
+}
+
+// turnComplete records a completed turn, updating the rate at most every rateInterval.
+func (h *hud) turnComplete(turn int, now time.Time) {
+	h.turn = turn
+	if h.rateTime.IsZero() {
+		h.rateTurn, h.rateTime = turn, now
+		return
+	}
+	if elapsed := now.Sub(h.rateTime); elapsed >= rateInterval {
+		h.rate = float64(turn-h.rateTurn) / elapsed.Seconds()
+		h.rateTurn, h.rateTime = turn, now
+	}
+}
+
+// stateChange records a change of state. The rate is started afresh, as no turns pass while paused.
+func (h *hud) stateChange(e gol.StateChange) {
+	h.paused = e.NewState == gol.Paused
+	h.turn = e.CompletedTurns
+	h.rate = 0
+	h.rateTime = time.Time{}
+}
+
+// lines returns the text of the overlay.
+func (h *hud) lines(alive int) []string {
+	state := gol.Executing
+	if h.paused {
+		state = gol.Paused
+	}
+	return []string{
+		fmt.Sprintf("Turn  %v", h.turn),
+		fmt.Sprintf("Alive %v", alive),
+		fmt.Sprintf("Rate  %.0f/s", h.rate),
+		fmt.Sprintf("State %v", state),
+		fmt.Sprintf("Rule  %v", gol.Rule),
+	}
+}
+
+// textRects appends the dots of text drawn with its top left corner at the pixel (x, y).
+// Letters are drawn in upper case, and anything the font does not have is left blank.
+func textRects(rects []sdl.Rect, text string, x, y int32) []sdl.Rect {
+	for _, r := range strings.ToUpper(text) {
+		glyph := font[r]
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<uint(glyphWidth-1-col)) != 0 {
+					rects = append(rects, sdl.Rect{
+						X: x + int32(col)*hudScale,
+						Y: y + int32(row)*hudScale,
+						W: hudScale,
+						H: hudScale,
+					})
+				}
+			}
+		}
+		x += (glyphWidth + 1) * hudScale
+	}
+	return rects
+}
+
+// drawOverlay draws lines of text on a translucent panel in the top left corner of the window.
+func (w *Window) drawOverlay(lines []string) {
+	longest := 0
+	for _, line := range lines {
+		if len(line) > longest {
+			longest = len(line)
+		}
+	}
+	lineHeight := int32(glyphHeight+3) * hudScale
+	panel := sdl.Rect{
+		X: 0,
+		Y: 0,
+		W: int32(longest*(glyphWidth+1))*hudScale + 2*hudMargin,
+		H: int32(len(lines))*lineHeight + 2*hudMargin - 3*hudScale,
+	}
+	err := w.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	util.Check(err)
+	err = w.renderer.SetDrawColor(0, 0, 0, 0xB0)
+	util.Check(err)
+	err = w.renderer.FillRect(&panel)
+	util.Check(err)
+
+	w.overlayRects = w.overlayRects[:0]
+	for i, line := range lines {
+		w.overlayRects = textRects(w.overlayRects, line, hudMargin, hudMargin+int32(i)*lineHeight)
+	}
+	if len(w.overlayRects) > 0 {
+		err = w.renderer.SetDrawColor(0xFF, 0xFF, 0xFF, 0xFF)
+		util.Check(err)
+		err = w.renderer.FillRects(w.overlayRects)
+		util.Check(err)
+	}
+}
@@ -2,20 +2,72 @@ package sdl
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
-func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+// Options are the settings of the window that are not part of gol.Params.
+type Options struct {
+	// Pattern is placed with its top left corner at the cursor by a right click while paused.
+	Pattern util.Pattern
+	// Theme is the name of the theme in Themes to start with, or empty for the first one.
+	Theme string
+	// Age colours alive cells by how long they have survived and leaves a fading trail behind dead ones.
+	Age bool
+	// HideHUD starts the window without the overlay showing the turn, population, rate, state and rule.
+	HideHUD bool
+	// Graph starts the window with the population graph showing.
+	Graph bool
+	// Keys binds extra keys to those forwarded to the run: pressing a key in Keys acts like pressing the
+	// key it maps to, one of p, s, q, k, n, b, + and -.
+	Keys map[rune]rune
+}
+
+// Run shows the events of a run in a window and forwards keypresses to it. While the run is paused,
+// clicking or dragging with the left button toggles cells by sending them on edits, which may be nil.
+// The mouse wheel zooms, dragging with the middle button (or the left button while running) pans,
+// and 'f' fits the whole world in the window. 't' cycles through the themes, 'a' toggles colouring by age
+// and 'h' and 'g' toggle the overlay and the population graph. The population after every turn is
+// saved to the output directory as a CSV file when the run ends.
+func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, edits chan<- util.Cell, opts Options) {
+	theme := 0
+	if opts.Theme != "" {
+		var err error
+		theme, err = FindTheme(opts.Theme)
+		util.Check(err)
+	}
 	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
+	w.SetTheme(theme, opts.Age)
+	if opts.Graph {
+		w.ToggleGraph()
+	}
+	var summary gol.MetricsSummary
+	var population gol.Population
+	ed := editor{edits: edits, pattern: opts.Pattern}
+	overlay := hud{visible: !opts.HideHUD}
+	showHUD := func() {
+		if overlay.visible {
+			w.SetOverlay(overlay.lines(w.CountPixels()))
+		} else {
+			w.SetOverlay(nil)
+		}
+	}
 
 sdlLoop:
 	for {
 		event := w.PollEvent()
 		if event != nil {
+			viewChanged := false
 			switch e := event.(type) {
 			case *sdl.KeyboardEvent:
-				switch e.Keysym.Sym {
+				sym := e.Keysym.Sym
+				if key, ok := opts.Keys[rune(sym)]; ok {
+					sym = sdl.Keycode(key)
+				}
+				switch sym {
 				case sdl.K_p:
 					keyPresses <- 'p'
 				case sdl.K_s:
@@ -24,9 +76,63 @@ sdlLoop:
 					keyPresses <- 'q'
 				case sdl.K_k:
 					keyPresses <- 'k'
+				case sdl.K_n:
+					keyPresses <- 'n'
+				case sdl.K_b:
+					keyPresses <- 'b'
+				case sdl.K_PLUS, sdl.K_EQUALS, sdl.K_KP_PLUS:
+					keyPresses <- '+'
+				case sdl.K_MINUS, sdl.K_KP_MINUS:
+					keyPresses <- '-'
+				case sdl.K_f:
+					w.FitToWindow()
+					viewChanged = true
+				case sdl.K_t:
+					fmt.Printf("Theme %v\n", w.NextTheme())
+					viewChanged = true
+				case sdl.K_a:
+					fmt.Printf("Age colouring %v\n", w.ToggleAge())
+					viewChanged = true
+				case sdl.K_h:
+					overlay.visible = !overlay.visible
+					showHUD()
+					viewChanged = true
+				case sdl.K_g:
+					w.ToggleGraph()
+					viewChanged = true
+				}
+			case *sdl.MouseButtonEvent:
+				ed.handle(w, e)
+			case *sdl.MouseMotionEvent:
+				// The middle button always drags the view; the left button only does while running,
+				// as it edits cells while paused.
+				if e.State&sdl.ButtonMMask() != 0 || (e.State&sdl.ButtonLMask() != 0 && !ed.paused) {
+					w.Pan(e.XRel, e.YRel)
+					viewChanged = true
+				} else {
+					ed.handle(w, e)
 				}
+			case *sdl.MouseWheelEvent:
+				x, y, _ := sdl.GetMouseState()
+				if e.Y > 0 {
+					w.ZoomIn(x, y)
+				} else if e.Y < 0 {
+					w.ZoomOut(x, y)
+				}
+				viewChanged = true
+			case *sdl.WindowEvent:
+				if e.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
+					w.Resize()
+					viewChanged = true
+				}
+			}
+			// A running simulation redraws on the next TurnComplete anyway, and redrawing now could
+			// show a turn that is only partly flipped.
+			if viewChanged && ed.paused {
+				w.RenderFrame()
 			}
 		}
+		ed.flush()
 		select {
 		case event, ok := <-events:
 			if !ok {
@@ -36,11 +142,38 @@ sdlLoop:
 			switch e := event.(type) {
 			case gol.CellFlipped:
 				w.FlipPixel(e.Cell.X, e.Cell.Y)
+			case gol.CellsFlipped:
+				for _, cell := range e.Cells {
+					w.FlipPixel(cell.X, cell.Y)
+				}
 			case gol.TurnComplete:
+				w.SetTurn(e.CompletedTurns)
+				overlay.turnComplete(e.CompletedTurns, time.Now())
+				showHUD()
+				population.Record(e.CompletedTurns, w.CountPixels())
+				w.PlotPopulation(population.Counts())
 				w.RenderFrame()
+			case gol.WorkerMetrics:
+				if summary.Add(e) {
+					fmt.Print(summary.Flush())
+				}
 			case gol.FinalTurnComplete:
+				fmt.Print(summary.Flush())
+				if path, err := population.Save(p); err != nil {
+					fmt.Println("Could not save the population series:", err)
+				} else {
+					fmt.Println("Population series saved to", path)
+				}
 				w.Destroy()
 				break sdlLoop
+			case gol.StateChange:
+				ed.paused = e.NewState == gol.Paused
+				overlay.stateChange(e)
+				showHUD()
+				if ed.paused {
+					w.RenderFrame()
+				}
+				fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
 			default:
 				if len(event.String()) > 0 {
 					fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
@@ -0,0 +1,97 @@
+package sdl
+
+import "fmt"
+
+const (
+	// maxAge is the number of turns after which an alive cell has reached the oldest colour.
+	maxAge = 64
+	// trailLength is the number of turns it takes a dead cell's trail to fade away.
+	trailLength = 16
+)
+
+// colour is an opaque RGB colour.
+type colour struct {
+	R, G, B uint8
+}
+
+// Theme is a palette for the window. In age mode alive cells shade from Young to Old as they survive,
+// and cells that have just died leave a Trail that fades into Dead.
+type Theme struct {
+	Name        string
+	Dead, Alive colour
+	Young, Old  colour
+	Trail       colour
+}
+
+// Themes are the palettes that can be chosen with the -theme flag and cycled through with 't'.
+var Themes = []Theme{
+	{
+		Name:  "classic",
+		Dead:  colour{0x00, 0x00, 0x00},
+		Alive: colour{0xFF, 0xFF, 0xFF},
+		Young: colour{0xFF, 0xFF, 0xFF},
+		Old:   colour{0x30, 0x60, 0xFF},
+		Trail: colour{0x80, 0x20, 0x20},
+	},
+	{
+		Name:  "phosphor",
+		Dead:  colour{0x00, 0x10, 0x00},
+		Alive: colour{0x33, 0xFF, 0x33},
+		Young: colour{0xCC, 0xFF, 0xCC},
+		Old:   colour{0x00, 0x80, 0x00},
+		Trail: colour{0x00, 0x50, 0x00},
+	},
+	{
+		Name:  "amber",
+		Dead:  colour{0x10, 0x08, 0x00},
+		Alive: colour{0xFF, 0xB0, 0x00},
+		Young: colour{0xFF, 0xE0, 0x80},
+		Old:   colour{0xC0, 0x40, 0x00},
+		Trail: colour{0x60, 0x20, 0x00},
+	},
+	{
+		Name:  "paper",
+		Dead:  colour{0xF0, 0xF0, 0xE8},
+		Alive: colour{0x10, 0x10, 0x10},
+		Young: colour{0xD0, 0x20, 0x20},
+		Old:   colour{0x10, 0x10, 0x10},
+		Trail: colour{0xC0, 0xC0, 0xB8},
+	},
+}
+
+// FindTheme returns the index in Themes of the theme with the given name.
+func FindTheme(name string) (int, error) {
+	for i, theme := range Themes {
+		if theme.Name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown theme %q", name)
+}
+
+// blend mixes a and b, with t running from 0 (all a) to 1 (all b).
+func blend(a, b colour, t float64) colour {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t)
+	}
+	return colour{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B)}
+}
+
+// cellColour picks the colour of a cell that has been alive, or dead, for age turns.
+// A negative age means the cell has never been alive.
+func (theme *Theme) cellColour(alive, ageMode bool, age int) colour {
+	switch {
+	case !ageMode && alive:
+		return theme.Alive
+	case !ageMode || age < 0:
+		return theme.Dead
+	case alive && age >= maxAge:
+		return theme.Old
+	case alive:
+		return blend(theme.Young, theme.Old, float64(age)/maxAge)
+	case age >= trailLength:
+		return theme.Dead
+	default:
+		return blend(theme.Trail, theme.Dead, float64(age)/trailLength)
+	}
+}
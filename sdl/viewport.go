@@ -0,0 +1,133 @@
+package sdl
+
+import (
+	"math"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const (
+	// maxZoom is the most pixels a single cell may take up.
+	maxZoom = 64
+	// minZoom is the smallest fraction of a pixel a single cell may take up.
+	minZoom = 1.0 / 64
+	// gridZoom is the zoom from which lines are drawn between cells.
+	gridZoom = 8
+)
+
+// viewport maps cells of the world onto pixels of the window. zoom is the number of pixels per cell:
+// a whole number when zoomed in, or one over a power of two when zoomed out to show a world bigger
+// than the window.
+type viewport struct {
+	worldWidth, worldHeight int
+	width, height           int32
+	zoom                    float64
+	// x and y are the world coordinates shown at the top left corner of the window.
+	x, y float64
+}
+
+// fitZoom returns the largest allowed zoom at which the whole world fits in the window.
+func (v *viewport) fitZoom() float64 {
+	fit := math.Min(float64(v.width)/float64(v.worldWidth), float64(v.height)/float64(v.worldHeight))
+	if fit >= 1 {
+		return math.Min(math.Floor(fit), maxZoom)
+	}
+	zoom := 1.0
+	for zoom > fit && zoom > minZoom {
+		zoom /= 2
+	}
+	return zoom
+}
+
+// fit shows the whole world, centred in the window.
+func (v *viewport) fit() {
+	v.zoom = v.fitZoom()
+	v.clamp()
+}
+
+// setZoom changes the zoom while keeping the cell under the pixel (px, py) where it is.
+func (v *viewport) setZoom(zoom float64, px, py int32) {
+	zoom = math.Max(minZoom, math.Min(maxZoom, zoom))
+	cellX := v.x + float64(px)/v.zoom
+	cellY := v.y + float64(py)/v.zoom
+	v.zoom = zoom
+	v.x = cellX - float64(px)/v.zoom
+	v.y = cellY - float64(py)/v.zoom
+	v.clamp()
+}
+
+// zoomIn zooms in one step around the pixel (px, py).
+func (v *viewport) zoomIn(px, py int32) {
+	if v.zoom < 1 {
+		v.setZoom(v.zoom*2, px, py)
+	} else {
+		v.setZoom(v.zoom+1, px, py)
+	}
+}
+
+// zoomOut zooms out one step around the pixel (px, py).
+func (v *viewport) zoomOut(px, py int32) {
+	if v.zoom > 1 {
+		v.setZoom(v.zoom-1, px, py)
+	} else {
+		v.setZoom(v.zoom/2, px, py)
+	}
+}
+
+// pan moves the view by a number of pixels, as when the world is dragged by the mouse.
+func (v *viewport) pan(dx, dy int32) {
+	v.x -= float64(dx) / v.zoom
+	v.y -= float64(dy) / v.zoom
+	v.clamp()
+}
+
+// resize adapts the view to a new window size.
+func (v *viewport) resize(width, height int32) {
+	v.width, v.height = width, height
+	v.clamp()
+}
+
+// clamp centres any dimension of the world that fits in the window, and otherwise stops the view
+// from moving past the edges of the world.
+func (v *viewport) clamp() {
+	v.x = clampAxis(v.x, float64(v.width)/v.zoom, float64(v.worldWidth))
+	v.y = clampAxis(v.y, float64(v.height)/v.zoom, float64(v.worldHeight))
+}
+
+func clampAxis(offset, shown, size float64) float64 {
+	if shown >= size {
+		return (size - shown) / 2
+	}
+	return math.Max(0, math.Min(offset, size-shown))
+}
+
+// cellAt returns the cell under the pixel (px, py), if there is one.
+func (v *viewport) cellAt(px, py int32) (util.Cell, bool) {
+	x := int(math.Floor(v.x + float64(px)/v.zoom))
+	y := int(math.Floor(v.y + float64(py)/v.zoom))
+	if x < 0 || y < 0 || x >= v.worldWidth || y >= v.worldHeight {
+		return util.Cell{}, false
+	}
+	return util.Cell{X: x, Y: y}, true
+}
+
+// visible returns the cells that can be seen as a rectangle of the world, and where on the window
+// they go. The rectangles are empty if no cell can be seen.
+func (v *viewport) visible() (cells, pixels sdl.Rect) {
+	x0 := int32(math.Max(0, math.Floor(v.x)))
+	y0 := int32(math.Max(0, math.Floor(v.y)))
+	x1 := int32(math.Min(float64(v.worldWidth), math.Ceil(v.x+float64(v.width)/v.zoom)))
+	y1 := int32(math.Min(float64(v.worldHeight), math.Ceil(v.y+float64(v.height)/v.zoom)))
+	if x1 <= x0 || y1 <= y0 {
+		return sdl.Rect{}, sdl.Rect{}
+	}
+	cells = sdl.Rect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0}
+	pixels = sdl.Rect{
+		X: int32(math.Round((float64(x0) - v.x) * v.zoom)),
+		Y: int32(math.Round((float64(y0) - v.y) * v.zoom)),
+		W: int32(math.Round(float64(cells.W) * v.zoom)),
+		H: int32(math.Round(float64(cells.H) * v.zoom)),
+	}
+	return cells, pixels
+}
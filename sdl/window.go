@@ -2,6 +2,7 @@ package sdl
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/util"
@@ -13,34 +14,128 @@ type Window struct {
 	renderer      *sdl.Renderer
 	texture       *sdl.Texture
 	pixels        []byte
+	view          viewport
+
+	// alive holds the state of every cell, and changed the turn on which each last flipped,
+	// so that the pixels can be coloured by age whenever a frame is drawn.
+	alive   []bool
+	changed []int
+	turn    int
+	theme   int
+	ageMode bool
+	palette palette
+
+	// population is the number of alive cells, kept up to date as cells flip.
+	population int
+	// overlay is the text drawn over the top left corner of the world, if any.
+	overlay      []string
+	overlayRects []sdl.Rect
+	graph        graph
+}
+
+// neverChanged marks a cell that has not flipped since the window was opened.
+const neverChanged = math.MinInt32
+
+// palette holds the colours of the current theme for every age a cell can be drawn at, so that
+// drawing a frame needs no blending.
+type palette struct {
+	alive [maxAge + 1]colour
+	dead  [trailLength + 1]colour
+	empty colour
 }
 
 func filterEvent(e sdl.Event, userdata interface{}) bool {
-	return e.GetType() == sdl.KEYDOWN || e.GetType() == sdl.QUIT
+	switch e.GetType() {
+	case sdl.KEYDOWN, sdl.QUIT, sdl.MOUSEBUTTONDOWN, sdl.MOUSEBUTTONUP, sdl.MOUSEMOTION, sdl.MOUSEWHEEL, sdl.WINDOWEVENT:
+		return true
+	}
+	return false
 }
 
+// NewWindow opens a window onto a width x height world, zoomed so that the world fills most of the
+// screen without being bigger than it.
 func NewWindow(width, height int32) *Window {
 	err := sdl.Init(sdl.INIT_EVERYTHING)
 	util.Check(err)
-	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, width, height, sdl.WINDOW_SHOWN)
+
+	view := viewport{worldWidth: int(width), worldHeight: int(height), width: width, height: height, zoom: 1}
+	if bounds, err := sdl.GetDisplayBounds(0); err == nil {
+		view.resize(bounds.W*4/5, bounds.H*4/5)
+		view.fit()
+		view.resize(int32(float64(width)*view.zoom), int32(float64(height)*view.zoom))
+		view.fit()
+	}
+
+	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, view.width, view.height, sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	util.Check(err)
 	renderer, err := sdl.CreateRenderer(window, -1, sdl.WINDOW_SHOWN)
 	util.Check(err)
-	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "linear")
-	err = renderer.SetLogicalSize(width, height)
-	util.Check(err)
+	// Cells must stay crisp squares when zoomed in.
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "nearest")
 	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, width, height)
 	util.Check(err)
 
 	sdl.SetEventFilterFunc(filterEvent, nil)
-	return &Window{
-		width,
-		height,
-		window,
-		renderer,
-		texture,
-		make([]byte, width*height*4),
+	w := &Window{
+		Width:    width,
+		Height:   height,
+		window:   window,
+		renderer: renderer,
+		texture:  texture,
+		pixels:   make([]byte, width*height*4),
+		view:     view,
+		alive:    make([]bool, width*height),
+		changed:  make([]int, width*height),
+	}
+	w.ClearPixels()
+	w.SetTheme(0, false)
+	return w
+}
+
+// SetTheme picks the theme with the given index in Themes, and whether cells are coloured by age.
+func (w *Window) SetTheme(theme int, ageMode bool) {
+	w.theme, w.ageMode = theme, ageMode
+	t := &Themes[theme]
+	for age := range w.palette.alive {
+		w.palette.alive[age] = t.cellColour(true, ageMode, age)
+	}
+	for age := range w.palette.dead {
+		w.palette.dead[age] = t.cellColour(false, ageMode, age)
 	}
+	w.palette.empty = t.cellColour(false, ageMode, -1)
+}
+
+// NextTheme switches to the next theme in Themes and returns its name.
+func (w *Window) NextTheme() string {
+	w.SetTheme((w.theme+1)%len(Themes), w.ageMode)
+	return Themes[w.theme].Name
+}
+
+// ToggleAge switches colouring cells by age on or off and returns whether it is now on.
+func (w *Window) ToggleAge() bool {
+	w.SetTheme(w.theme, !w.ageMode)
+	return w.ageMode
+}
+
+// SetOverlay sets the lines of text drawn over the world from the next frame on. nil removes the overlay.
+func (w *Window) SetOverlay(lines []string) {
+	w.overlay = lines
+}
+
+// PlotPopulation updates the population graph with a series as returned by gol.Population.Counts.
+func (w *Window) PlotPopulation(counts []int32) {
+	w.graph.update(counts)
+}
+
+// ToggleGraph shows or hides the population graph and returns whether it is now shown.
+func (w *Window) ToggleGraph() bool {
+	w.graph.visible = !w.graph.visible
+	return w.graph.visible
+}
+
+// SetTurn records the turn that the cells now show. Cells flipped after it are aged from the turn after.
+func (w *Window) SetTurn(turn int) {
+	w.turn = turn
 }
 
 func (w *Window) Destroy() {
@@ -53,26 +148,124 @@ func (w *Window) Destroy() {
 	sdl.Quit()
 }
 
+// RenderFrame draws the cells that are in view. Only that part of the texture is updated, so large
+// worlds cost no more to draw than the window can show.
 func (w *Window) RenderFrame() {
-	err := w.texture.Update(nil, w.pixels, int(w.Width*4))
+	cells, pixels := w.view.visible()
+	err := w.renderer.SetDrawColor(0, 0, 0, 0xFF)
 	util.Check(err)
 	err = w.renderer.Clear()
 	util.Check(err)
-	err = w.renderer.Copy(w.texture, nil, nil)
-	util.Check(err)
+	if cells.W > 0 && cells.H > 0 {
+		w.paint(cells)
+		start := 4 * (int(cells.Y)*int(w.Width) + int(cells.X))
+		err = w.texture.Update(&cells, w.pixels[start:], int(w.Width*4))
+		util.Check(err)
+		err = w.renderer.Copy(w.texture, &cells, &pixels)
+		util.Check(err)
+		if w.view.zoom >= gridZoom {
+			w.drawGrid(cells, pixels)
+		}
+	}
+	if len(w.overlay) > 0 {
+		w.drawOverlay(w.overlay)
+	}
+	if w.graph.visible {
+		w.drawGraph(&w.graph)
+	}
 	w.renderer.Present()
 }
 
+// paint colours the pixels of the given cells from their state and age.
+func (w *Window) paint(cells sdl.Rect) {
+	width := int(w.Width)
+	for y := int(cells.Y); y < int(cells.Y+cells.H); y++ {
+		for x := int(cells.X); x < int(cells.X+cells.W); x++ {
+			i := y*width + x
+			var c colour
+			switch age := w.turn - w.changed[i]; {
+			case w.changed[i] == neverChanged:
+				c = w.palette.empty
+			case age < 0:
+				// Stepping back flips cells on turns the window has already shown.
+				age = 0
+				fallthrough
+			default:
+				if w.alive[i] {
+					c = w.palette.alive[min(age, maxAge)]
+				} else {
+					c = w.palette.dead[min(age, trailLength)]
+				}
+			}
+			// PIXELFORMAT_ARGB8888 is stored in memory as blue, green, red, alpha.
+			w.pixels[4*i+0] = c.B
+			w.pixels[4*i+1] = c.G
+			w.pixels[4*i+2] = c.R
+			w.pixels[4*i+3] = 0xFF
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// drawGrid draws lines between the cells in view.
+func (w *Window) drawGrid(cells, pixels sdl.Rect) {
+	err := w.renderer.SetDrawColor(0x40, 0x40, 0x40, 0xFF)
+	util.Check(err)
+	zoom := int32(w.view.zoom)
+	for i := int32(0); i <= cells.W; i++ {
+		x := pixels.X + i*zoom
+		err = w.renderer.DrawLine(x, pixels.Y, x, pixels.Y+pixels.H)
+		util.Check(err)
+	}
+	for i := int32(0); i <= cells.H; i++ {
+		y := pixels.Y + i*zoom
+		err = w.renderer.DrawLine(pixels.X, y, pixels.X+pixels.W, y)
+		util.Check(err)
+	}
+}
+
+// ZoomIn zooms in one step, keeping the cell under the pixel (x, y) in place.
+func (w *Window) ZoomIn(x, y int32) {
+	w.view.zoomIn(x, y)
+}
+
+// ZoomOut zooms out one step, keeping the cell under the pixel (x, y) in place.
+func (w *Window) ZoomOut(x, y int32) {
+	w.view.zoomOut(x, y)
+}
+
+// Pan moves the view by a number of pixels.
+func (w *Window) Pan(dx, dy int32) {
+	w.view.pan(dx, dy)
+}
+
+// FitToWindow zooms so that the whole world is shown.
+func (w *Window) FitToWindow() {
+	w.view.fit()
+}
+
+// Resize adapts the view after the window has been resized.
+func (w *Window) Resize() {
+	w.view.resize(w.window.GetSize())
+}
+
 func (w *Window) PollEvent() sdl.Event {
 	return sdl.PollEvent()
 }
 
 func (w *Window) SetPixel(x, y int) {
-	width := int(w.Width)
-	w.pixels[4*(y*width+x)+0] = 0xFF
-	w.pixels[4*(y*width+x)+1] = 0xFF
-	w.pixels[4*(y*width+x)+2] = 0xFF
-	w.pixels[4*(y*width+x)+3] = 0xFF
+	i := y*int(w.Width) + x
+	if !w.alive[i] {
+		w.population++
+	}
+	w.alive[i] = true
+	w.changed[i] = w.turn
 }
 
 func (w *Window) FlipPixel(x, y int) {
@@ -80,25 +273,34 @@ func (w *Window) FlipPixel(x, y int) {
 		panic(fmt.Sprintf("CellFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
 	}
 
-	width := int(w.Width)
-	w.pixels[4*(y*width+x)+0] = ^w.pixels[4*(y*width+x)+0]
-	w.pixels[4*(y*width+x)+1] = ^w.pixels[4*(y*width+x)+1]
-	w.pixels[4*(y*width+x)+2] = ^w.pixels[4*(y*width+x)+2]
-	w.pixels[4*(y*width+x)+3] = ^w.pixels[4*(y*width+x)+3]
+	i := y*int(w.Width) + x
+	w.alive[i] = !w.alive[i]
+	w.changed[i] = w.turn + 1
+	if w.alive[i] {
+		w.population++
+	} else {
+		w.population--
+	}
+}
+
+// CellAt returns the cell under a point of the window, as given by a mouse event.
+func (w *Window) CellAt(x, y int32) (util.Cell, bool) {
+	return w.view.cellAt(x, y)
+}
+
+// IsSet reports whether a cell is currently alive.
+func (w *Window) IsSet(x, y int) bool {
+	return w.alive[y*int(w.Width)+x]
 }
 
 func (w *Window) CountPixels() int {
-	count := 0
-	for i := 0; i < int(w.Width) * int(w.Height) * 4; i += 4 {
-		if w.pixels[i] == 0xFF {
-			count++
-		}
-	}
-	return count
+	return w.population
 }
 
 func (w *Window) ClearPixels() {
-	for i := range w.pixels {
-		w.pixels[i] = 0
+	for i := range w.alive {
+		w.alive[i] = false
+		w.changed[i] = neverChanged
 	}
+	w.population = 0
 }
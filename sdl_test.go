@@ -10,6 +10,7 @@ import (
 
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 var sdlEvents chan gol.Event
@@ -26,9 +27,7 @@ func TestMain(m *testing.M) {
 	result := make(chan int)
 	go func() {
 		res := m.Run()
-		go func() {
-			sdlEvents <- gol.FinalTurnComplete{}
-		}()
+		close(sdlEvents)
 		result <- res
 	}()
 	// sdl.Run(p, sdlEvents, nil)
@@ -59,6 +58,13 @@ sdlLoop:
 				if w != nil {
 					w.FlipPixel(e.Cell.X, e.Cell.Y)
 				}
+			case gol.CellsFlipped:
+				for _, cell := range e.Cells {
+					board[cell.Y][cell.X] = ^board[cell.Y][cell.X]
+					if w != nil {
+						w.FlipPixel(cell.X, cell.Y)
+					}
+				}
 			case gol.TurnComplete:
 				if w != nil {
 					w.RenderFrame()
@@ -73,10 +79,16 @@ sdlLoop:
 				}
 				sdlAlive <- count
 			case gol.FinalTurnComplete:
+				// The next run starts from an empty board again.
+				for y := range board {
+					for x := range board[y] {
+						board[y][x] = 0
+					}
+				}
 				if w != nil {
-					w.Destroy()
+					w.ClearPixels()
+					w.RenderFrame()
 				}
-				break sdlLoop
 			default:
 				if len(event.String()) > 0 {
 					fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
@@ -89,40 +101,64 @@ sdlLoop:
 	os.Exit(<-result)
 }
 
-// TestSdl tests a 512x512 image for 100 turns using 8 worker threads.
+// TestSdl tests a 512x512 image for 100 turns using 8 worker threads, with a CellFlipped event per
+// cell, with the cells of each turn batched into a CellsFlipped event, and replayed from a recording
+// of the batched run without a live engine.
 func TestSdl(t *testing.T) {
-	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100, Threads: 8}
-	testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
-	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
-	t.Run(testName, func(t *testing.T) {
-		turnNum := 0
-		events := make(chan gol.Event)
-		go gol.Run(p, events, nil)
-		time.Sleep(2 * time.Second)
-		final := false
-		for event := range events {
-			switch e := event.(type) {
-			case gol.CellFlipped:
-				sdlEvents <- e
-			case gol.TurnComplete:
-				turnNum++
-				sdlEvents <- e
-				aliveCount := <-sdlAlive
-				if alive[turnNum] != aliveCount {
-					t.Logf("Incorrect number of alive cells displayed on turn %d. Was %d, should be %d.", turnNum, aliveCount, alive[turnNum])
-					time.Sleep(5 * time.Second)
+	tests := []struct {
+		suffix        string
+		batch, replay bool
+	}{
+		{"", false, false},
+		{"-batch", true, false},
+		{"-replay", true, true},
+	}
+	for _, test := range tests {
+		p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100, Threads: 8, BatchFlips: test.batch}
+		testName := fmt.Sprintf("%dx%dx%d-%d%v", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads, test.suffix)
+		alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+		replay := test.replay
+		t.Run(testName, func(t *testing.T) {
+			turnNum := 0
+			events := make(chan gol.Event)
+			if replay {
+				log, _, err := recordRun(p)
+				if err != nil {
 					sdlEvents <- gol.FinalTurnComplete{}
-					t.FailNow()
+					t.Fatal("Could not record the run to replay:", err)
+				}
+				er, err := gol.NewEventReader(log)
+				util.Check(err)
+				go gol.Replay(er, events, nil, 0)
+			} else {
+				go gol.Run(p, events, nil)
+			}
+			time.Sleep(2 * time.Second)
+			final := false
+			for event := range events {
+				switch e := event.(type) {
+				case gol.CellFlipped, gol.CellsFlipped:
+					sdlEvents <- e
+				case gol.TurnComplete:
+					turnNum++
+					sdlEvents <- e
+					aliveCount := <-sdlAlive
+					if alive[turnNum] != aliveCount {
+						t.Logf("Incorrect number of alive cells displayed on turn %d. Was %d, should be %d.", turnNum, aliveCount, alive[turnNum])
+						time.Sleep(5 * time.Second)
+						sdlEvents <- gol.FinalTurnComplete{}
+						t.FailNow()
+					}
+				case gol.FinalTurnComplete:
+					final = true
+					sdlEvents <- e
 				}
-			case gol.FinalTurnComplete:
-				final = true
-				sdlEvents <- e
 			}
-		}
 
-		if !final {
-			sdlEvents <- gol.FinalTurnComplete{}
-			t.Fatal("Simulation finished without sending a FinalTurnComplete event.")
-		}
-	})
+			if !final {
+				sdlEvents <- gol.FinalTurnComplete{}
+				t.Fatal("Simulation finished without sending a FinalTurnComplete event.")
+			}
+		})
+	}
 }
@@ -0,0 +1,164 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// frameInterval is the shortest time between two frames, as a terminal cannot keep up with every turn.
+const frameInterval = time.Second / 30
+
+// Run shows the events of a run in the terminal, for machines where no window can be opened.
+// The terminal is put into raw mode so that p, s, q, k, n, b, + and - can be forwarded to keyPresses
+// as soon as they are pressed, and the arrow keys scroll the view when the world does not fit.
+// Ctrl-C quits like q, and a key in bindings acts like the key it maps to. The terminal is restored once
+// the run has finished, and only then are the messages gol printed in the meantime shown.
+func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, bindings map[rune]rune) {
+	var logged bytes.Buffer
+	previous := gol.SetOutput(&logged)
+	out := bufio.NewWriter(os.Stdout)
+	restore, err := makeRaw()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Could not read keys from the terminal:", err)
+	}
+	keys := make(chan rune, 10)
+	stopKeys := make(chan struct{})
+	keysStopped := make(chan struct{})
+	if restore != nil {
+		go func() {
+			readKeys(os.Stdin, keys, stopKeys)
+			close(keysStopped)
+		}()
+	} else {
+		close(keysStopped)
+	}
+
+	world := make([][]bool, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]bool, p.ImageWidth)
+	}
+	v := view{worldWidth: p.ImageWidth, worldHeight: p.ImageHeight}
+	rows, cols := size()
+	v.resize(rows, cols)
+
+	var summary gol.MetricsSummary
+	var status, message string
+	turn, alive := 0, 0
+	state := gol.Executing
+	dirty := true
+	// drawn holds the lines on the terminal, so that only those that have changed are drawn again.
+	var drawn []string
+
+	frames := time.NewTicker(frameInterval)
+	defer frames.Stop()
+	resize := time.NewTicker(time.Second)
+	defer resize.Stop()
+
+	out.WriteString(enterAltScreen + hideCursor)
+	draw := func() {
+		status = fmt.Sprintf("Turn %v  Alive %v  %v  View %v,%v %vx%v  %v",
+			turn, alive, state, v.x, v.y, v.width, v.height, message)
+		// A status line longer than the terminal would wrap and scroll the whole frame up.
+		line := status
+		if len(line) > cols {
+			line = line[:cols]
+		}
+		lines := append(v.lines(world), line)
+		for _, i := range changedLines(drawn, lines) {
+			out.WriteString(cursorTo(i) + lines[i] + clearLine)
+		}
+		out.WriteString(cursorTo(len(lines)) + clearBelow)
+		drawn = lines
+		out.Flush()
+		dirty = false
+	}
+
+tuiLoop:
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				break tuiLoop
+			}
+			switch e := event.(type) {
+			case gol.CellFlipped:
+				alive += flip(world, e.Cell)
+			case gol.CellsFlipped:
+				for _, cell := range e.Cells {
+					alive += flip(world, cell)
+				}
+			case gol.TurnComplete:
+				turn = e.CompletedTurns
+				dirty = true
+			case gol.AliveCellsCount:
+			case gol.WorkerMetrics:
+				summary.Add(e)
+			case gol.FinalTurnComplete:
+				turn = e.CompletedTurns
+				break tuiLoop
+			case gol.StateChange:
+				state = e.NewState
+				message = ""
+				dirty = true
+			default:
+				if len(event.String()) > 0 {
+					message = event.String()
+					dirty = true
+				}
+			}
+		case key := <-keys:
+			if bound, ok := bindings[key]; ok {
+				key = bound
+			}
+			switch {
+			case v.scrollKey(key):
+				dirty = true
+			case key == ctrlC:
+				keyPresses <- 'q'
+			case strings.ContainsRune("psqknb+-", key):
+				keyPresses <- key
+			}
+		case <-frames.C:
+			if dirty {
+				draw()
+			}
+		case <-resize.C:
+			if r, c := size(); r != rows || c != cols {
+				rows, cols = r, c
+				v.resize(rows, cols)
+				drawn = nil
+				dirty = true
+			}
+		}
+	}
+
+	draw()
+	out.WriteString(showCursor + leaveAltScreen)
+	out.Flush()
+	// The reader is stopped before the terminal is restored, so that it reads nothing typed afterwards.
+	close(stopKeys)
+	<-keysStopped
+	if restore != nil {
+		restore()
+	}
+	gol.SetOutput(previous)
+	fmt.Print(logged.String())
+	fmt.Println(status)
+	fmt.Print(summary.Flush())
+}
+
+// flip toggles a cell of world and returns the change in the number of cells alive.
+func flip(world [][]bool, cell util.Cell) int {
+	world[cell.Y][cell.X] = !world[cell.Y][cell.X]
+	if world[cell.Y][cell.X] {
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ANSI control sequences used to draw on the terminal.
+const (
+	enterAltScreen = "\x1b[?1049h"
+	leaveAltScreen = "\x1b[?1049l"
+	hideCursor     = "\x1b[?25l"
+	showCursor     = "\x1b[?25h"
+	clearLine      = "\x1b[K"
+	clearBelow     = "\x1b[J"
+)
+
+// cursorTo moves the cursor to the start of a row of the terminal, counting from 0.
+func cursorTo(row int) string {
+	return "\x1b[" + strconv.Itoa(row+1) + ";1H"
+}
+
+// Keys that have no rune of their own are read as runes from the private use area.
+const (
+	keyUp rune = 0xF700 + iota
+	keyDown
+	keyRight
+	keyLeft
+)
+
+// ctrlC is read instead of an interrupt while the terminal is in raw mode.
+const ctrlC = 0x03
+
+// stty runs the stty command on the terminal attached to stdin and returns its output.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// makeRaw puts the terminal into raw mode, so that keys are read as soon as they are pressed and
+// are not echoed. A read gives up after a tenth of a second without a key, so that readKeys can notice
+// it has been stopped. It returns a function that puts the terminal back as it was.
+func makeRaw() (restore func(), err error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo", "min", "0", "time", "1"); err != nil {
+		return nil, err
+	}
+	return func() {
+		stty(state)
+	}, nil
+}
+
+// size returns the number of rows and columns of the terminal, or 24x80 if it cannot be found.
+func size() (rows, cols int) {
+	out, err := stty("size")
+	if err == nil {
+		fields := strings.Fields(out)
+		if len(fields) == 2 {
+			rows, errRows := strconv.Atoi(fields[0])
+			cols, errCols := strconv.Atoi(fields[1])
+			if errRows == nil && errCols == nil && rows > 0 && cols > 0 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+// readKeys reads keys from in and sends them on keys until done is closed or in fails. A read that times
+// out without a key returns nothing, which only means that done is checked again, so that no key meant for
+// the shell is read once the terminal has been restored.
+// Arrow keys arrive as escape sequences and are sent as keyUp, keyDown, keyRight and keyLeft.
+func readKeys(in io.Reader, keys chan<- rune, done <-chan struct{}) {
+	buf := make([]byte, 64)
+	send := func(key rune) bool {
+		select {
+		case keys <- key:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		n, err := in.Read(buf)
+		if err != nil && err != io.EOF {
+			return
+		}
+		for i := 0; i < n; i++ {
+			key := rune(buf[i])
+			if buf[i] == 0x1b && i+2 < n && buf[i+1] == '[' && buf[i+2] >= 'A' && buf[i+2] <= 'D' {
+				key = keyUp + rune(buf[i+2]-'A')
+				i += 2
+			}
+			if !send(key) {
+				return
+			}
+		}
+	}
+}
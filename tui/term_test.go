@@ -0,0 +1,36 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadKeys checks that arrow keys are translated, and that readKeys returns once it is stopped,
+// even while nobody is receiving the keys it has read.
+func TestReadKeys(t *testing.T) {
+	keys := make(chan rune)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		readKeys(strings.NewReader("p\x1b[Aqk"), keys, done)
+		close(stopped)
+	}()
+	for _, expected := range []rune{'p', keyUp, 'q'} {
+		select {
+		case key := <-keys:
+			if key != expected {
+				t.Fatalf("Expected key %q, got %q", expected, key)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected key %q within 5 seconds", expected)
+		}
+	}
+
+	close(done)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("readKeys did not return within 5 seconds of being stopped")
+	}
+}
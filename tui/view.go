@@ -0,0 +1,83 @@
+package tui
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// view is the part of the world that fits in the terminal. Each character shows two cells, one above
+// the other, inside a border, with a status line below.
+type view struct {
+	worldWidth, worldHeight int
+	// x and y are the cell shown at the top left corner, and width and height the number of cells shown.
+	x, y          int
+	width, height int
+}
+
+// resize fits the view to a terminal with the given number of rows and columns.
+func (v *view) resize(rows, cols int) {
+	v.width = min(v.worldWidth, max(1, cols-2))
+	v.height = min(v.worldHeight, max(2, 2*(rows-3)))
+	v.clamp()
+}
+
+// scroll moves the view by a number of cells.
+func (v *view) scroll(dx, dy int) {
+	v.x += dx
+	v.y += dy
+	v.clamp()
+}
+
+// scrollKey moves the view a quarter of its size in the direction of an arrow key,
+// and reports whether the key was an arrow key.
+func (v *view) scrollKey(key rune) bool {
+	switch key {
+	case keyUp:
+		v.scroll(0, -max(2, v.height/4))
+	case keyDown:
+		v.scroll(0, max(2, v.height/4))
+	case keyLeft:
+		v.scroll(-max(1, v.width/4), 0)
+	case keyRight:
+		v.scroll(max(1, v.width/4), 0)
+	default:
+		return false
+	}
+	return true
+}
+
+// clamp stops the view from moving past the edges of the world.
+func (v *view) clamp() {
+	v.x = max(0, min(v.x, v.worldWidth-v.width))
+	v.y = max(0, min(v.y, v.worldHeight-v.height))
+}
+
+// lines draws the cells in view from world, one line of the terminal per string.
+func (v *view) lines(world [][]bool) []string {
+	return util.HalfBlocksToStrings(func(x, y int) bool {
+		return world[v.y+y][v.x+x]
+	}, v.width, v.height)
+}
+
+// changedLines returns the indexes of the lines of next that differ from those of previous, which is
+// all of them if previous has a different number of lines.
+func changedLines(previous, next []string) []int {
+	var changed []int
+	for i := range next {
+		if len(previous) != len(next) || previous[i] != next[i] {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
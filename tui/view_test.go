@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// parseWorld reads a world from rows of '#' for alive cells and '.' for dead ones.
+func parseWorld(rows ...string) [][]bool {
+	world := make([][]bool, len(rows))
+	for y, row := range rows {
+		world[y] = make([]bool, len(row))
+		for x, c := range row {
+			world[y][x] = c == '#'
+		}
+	}
+	return world
+}
+
+// TestHalfBlocks checks that each pair of cells is drawn as the right glyph, including the last row of a
+// world with an odd height, which has nothing below it.
+func TestHalfBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		world    []string
+		expected []string
+	}{
+		{"dead", []string{"..", ".."}, []string{"┌──┐", "│  │", "└──┘"}},
+		{"top only", []string{"#.", ".."}, []string{"┌──┐", "│▀ │", "└──┘"}},
+		{"bottom only", []string{"..", ".#"}, []string{"┌──┐", "│ ▄│", "└──┘"}},
+		{"both", []string{"#.", "#."}, []string{"┌──┐", "│█ │", "└──┘"}},
+		{"mixed", []string{"##.", ".#."}, []string{"┌───┐", "│▀█ │", "└───┘"}},
+		{"odd height", []string{"#..", ".#.", "#.#"}, []string{"┌───┐", "│▀▄ │", "│▀ ▀│", "└───┘"}},
+		{"one row", []string{".#"}, []string{"┌──┐", "│ ▀│", "└──┘"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			world := parseWorld(test.world...)
+			height, width := len(world), len(world[0])
+			lines := util.HalfBlocksToStrings(func(x, y int) bool {
+				if y >= height {
+					t.Fatalf("Cell %v,%v is below the world", x, y)
+				}
+				return world[y][x]
+			}, width, height)
+			if !reflect.DeepEqual(lines, test.expected) {
+				t.Errorf("Expected:\n%v\nGot:\n%v", strings.Join(test.expected, "\n"), strings.Join(lines, "\n"))
+			}
+		})
+	}
+}
+
+// TestViewLines checks that a view smaller than the world draws the part it has scrolled to, and that
+// it cannot be scrolled past the edges.
+func TestViewLines(t *testing.T) {
+	world := parseWorld(
+		"#...",
+		"....",
+		"..#.",
+		"...#",
+	)
+	v := view{worldWidth: 4, worldHeight: 4}
+	// Two rows of cells and a border above and below fit in 4 rows, with 1 left for the status line.
+	v.resize(4, 4)
+	if v.width != 2 || v.height != 2 {
+		t.Fatalf("Expected a 2x2 view, got %vx%v", v.width, v.height)
+	}
+	tests := []struct {
+		dx, dy   int
+		expected string
+	}{
+		{0, 0, "│▀ │"},
+		{2, 2, "│▀▄│"},
+		{5, 5, "│▀▄│"},
+		{-1, -9, "│  │"},
+	}
+	for _, test := range tests {
+		v.scroll(test.dx, test.dy)
+		lines := v.lines(world)
+		if len(lines) != 3 || lines[1] != test.expected {
+			t.Errorf("After scrolling by %v,%v to %v,%v expected %q, got %q", test.dx, test.dy, v.x, v.y, test.expected, lines)
+		}
+	}
+}
+
+// TestChangedLines checks that only the lines that differ from the last frame are drawn again, unless
+// the number of lines has changed.
+func TestChangedLines(t *testing.T) {
+	tests := []struct {
+		name           string
+		previous, next []string
+		expected       []int
+	}{
+		{"first frame", nil, []string{"a", "b"}, []int{0, 1}},
+		{"unchanged", []string{"a", "b"}, []string{"a", "b"}, nil},
+		{"one changed", []string{"a", "b", "c"}, []string{"a", "x", "c"}, []int{1}},
+		{"two changed", []string{"a", "b", "c"}, []string{"x", "b", "y"}, []int{0, 2}},
+		{"shorter", []string{"a", "b", "c"}, []string{"a", "b"}, []int{0, 1}},
+		{"longer", []string{"a"}, []string{"a", "b"}, []int{0, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if changed := changedLines(test.previous, test.next); !reflect.DeepEqual(changed, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, changed)
+			}
+		})
+	}
+}
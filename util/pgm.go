@@ -0,0 +1,95 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ParsePGM reads a binary (P5) PGM image as a world indexed [y][x]. Pixels brighter than half of the
+// image's maximum value are alive and come out as 255; every other pixel is dead and comes out as 0.
+func ParsePGM(r io.Reader) ([][]byte, error) {
+	return ParsePGMLimit(r, 0)
+}
+
+// ParsePGMLimit is ParsePGM for images that are not trusted. If maxCells is not 0, an image whose header
+// gives it more than maxCells pixels is rejected before any memory is set aside for them.
+func ParsePGMLimit(r io.Reader, maxCells int) ([][]byte, error) {
+	in := bufio.NewReader(r)
+	var header [4]string
+	for i := range header {
+		token, err := pgmToken(in)
+		if err != nil {
+			return nil, err
+		}
+		header[i] = token
+	}
+	if header[0] != "P5" {
+		return nil, errors.New("pgm: not a binary PGM image")
+	}
+	width, errWidth := strconv.Atoi(header[1])
+	height, errHeight := strconv.Atoi(header[2])
+	maxval, errMaxval := strconv.Atoi(header[3])
+	if errWidth != nil || errHeight != nil || errMaxval != nil || width <= 0 || height <= 0 || maxval <= 0 || maxval > 255 {
+		return nil, fmt.Errorf("pgm: malformed header %q", header)
+	}
+	if maxCells > 0 && width > maxCells/height {
+		return nil, fmt.Errorf("pgm: a %vx%v image has more than the %v pixels allowed", width, height, maxCells)
+	}
+
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+		if _, err := io.ReadFull(in, world[y]); err != nil {
+			return nil, fmt.Errorf("pgm: row %v: %v", y, err)
+		}
+		for x, value := range world[y] {
+			if int(value)*2 > maxval {
+				world[y][x] = 255
+			} else {
+				world[y][x] = 0
+			}
+		}
+	}
+	return world, nil
+}
+
+// pgmToken reads the next whitespace separated token of a PGM header, skipping '#' comments.
+// Exactly one whitespace character after the token is consumed, as the pixels follow straight after it.
+func pgmToken(in *bufio.Reader) (string, error) {
+	var token []byte
+	for {
+		b, err := in.ReadByte()
+		if err != nil {
+			return "", errors.New("pgm: truncated header")
+		}
+		switch {
+		case b == '#' && len(token) == 0:
+			if _, err := in.ReadString('\n'); err != nil {
+				return "", errors.New("pgm: truncated header")
+			}
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, b)
+		}
+	}
+}
+
+// WritePGM writes a world indexed [y][x] as a binary (P5) PGM image with a maximum value of 255.
+func WritePGM(w io.Writer, world [][]byte) error {
+	out := bufio.NewWriter(w)
+	width := 0
+	if len(world) > 0 {
+		width = len(world[0])
+	}
+	fmt.Fprintf(out, "P5\n%v %v\n255\n", width, len(world))
+	for _, row := range world {
+		out.Write(row)
+	}
+	return out.Flush()
+}
@@ -0,0 +1,126 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Pattern is a set of alive cells relative to the top left corner of its Width x Height bounding box.
+type Pattern struct {
+	Width, Height int
+	Alive         []Cell
+}
+
+// ReadRLE loads a pattern from a file in the run length encoded format used by most Life software.
+func ReadRLE(path string) (Pattern, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Pattern{}, err
+	}
+	defer file.Close()
+	return ParseRLE(file)
+}
+
+// ParseRLE reads a run length encoded pattern: '#' comment lines, a header line such as
+// "x = 3, y = 3, rule = B3/S23", then runs of 'b' (dead), 'o' (alive) and '$' (end of row) up to '!'.
+func ParseRLE(r io.Reader) (Pattern, error) {
+	var pattern Pattern
+	scanner := bufio.NewScanner(r)
+	header := false
+	x, y, run := 0, 0, 0
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !header {
+			for _, field := range strings.Split(line, ",") {
+				parts := strings.SplitN(field, "=", 2)
+				if len(parts) != 2 {
+					return Pattern{}, fmt.Errorf("rle: malformed header %q", line)
+				}
+				value := strings.TrimSpace(parts[1])
+				var err error
+				switch strings.TrimSpace(parts[0]) {
+				case "x":
+					pattern.Width, err = strconv.Atoi(value)
+				case "y":
+					pattern.Height, err = strconv.Atoi(value)
+				}
+				if err != nil {
+					return Pattern{}, fmt.Errorf("rle: malformed header %q", line)
+				}
+			}
+			if pattern.Width <= 0 || pattern.Height <= 0 {
+				return Pattern{}, fmt.Errorf("rle: the pattern must have a positive size, not %dx%d", pattern.Width, pattern.Height)
+			}
+			header = true
+			continue
+		}
+
+		for _, ch := range line {
+			switch {
+			case ch >= '0' && ch <= '9':
+				run = run*10 + int(ch-'0')
+				continue
+			case ch == 'b' || ch == '.':
+				x += count(run)
+			case ch == '$':
+				y += count(run)
+				x = 0
+			case ch == '!':
+				return pattern, nil
+			case ch == ' ' || ch == '\t':
+				continue
+			default:
+				for i := 0; i < count(run); i++ {
+					if x >= pattern.Width || y >= pattern.Height {
+						return Pattern{}, fmt.Errorf("rle: cell (%d, %d) is outside the %dx%d pattern", x, y, pattern.Width, pattern.Height)
+					}
+					pattern.Alive = append(pattern.Alive, Cell{X: x, Y: y})
+					x++
+				}
+			}
+			run = 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Pattern{}, err
+	}
+	if !header {
+		return Pattern{}, errors.New("rle: missing header line")
+	}
+	return pattern, nil
+}
+
+// count turns an RLE run length into a number of cells, where a missing count means one.
+func count(run int) int {
+	if run == 0 {
+		return 1
+	}
+	return run
+}
+
+// Centred returns a width x height world, indexed [y][x], that is dead apart from the pattern in the
+// middle of it.
+func (pattern Pattern) Centred(width, height int) ([][]byte, error) {
+	if pattern.Width > width || pattern.Height > height {
+		return nil, fmt.Errorf("the %vx%v pattern does not fit in a %vx%v world",
+			pattern.Width, pattern.Height, width, height)
+	}
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+	}
+	left, top := (width-pattern.Width)/2, (height-pattern.Height)/2
+	for _, cell := range pattern.Alive {
+		world[top+cell.Y][left+cell.X] = 255
+	}
+	return world, nil
+}
@@ -60,12 +60,11 @@ func matricesToString(given, expected [][]uint8, width, height int) string {
 }
 
 func getHorizontalBorder(start, middle, end string, width int) string {
-	border := start
-	for i := 0; i < width*2; i++ {
-		border += "─"
-	}
-	border += end
-	return border
+	return horizontalBorder(start, end, width*2)
+}
+
+func horizontalBorder(start, end string, length int) string {
+	return start + strings.Repeat("─", length) + end
 }
 
 func squaresToStrings(given, expected [][]uint8, width, height int) []string {
@@ -106,3 +105,30 @@ func squaresToStrings(given, expected [][]uint8, width, height int) []string {
 
 	return output
 }
+
+// halfBlocks draws a pair of cells, one above the other, as a single character.
+var halfBlocks = [2][2]string{{" ", "▄"}, {"▀", "█"}}
+
+// HalfBlocksToStrings draws a width x height part of a world in a box, one line per string.
+// Each character shows two cells, one above the other, so that cells come out roughly square.
+// alive reports whether the cell at (x, y) of the part being drawn is alive.
+func HalfBlocksToStrings(alive func(x, y int) bool, width, height int) []string {
+	bit := func(x, y int) int {
+		if y < height && alive(x, y) {
+			return 1
+		}
+		return 0
+	}
+	output := []string{horizontalBorder("┌", "┐", width)}
+	var line strings.Builder
+	for y := 0; y < height; y += 2 {
+		line.Reset()
+		line.WriteString("│")
+		for x := 0; x < width; x++ {
+			line.WriteString(halfBlocks[bit(x, y)][bit(x, y+1)])
+		}
+		line.WriteString("│")
+		output = append(output, line.String())
+	}
+	return append(output, horizontalBorder("└", "┘", width))
+}